@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-jwt/internal/infrastructure/driver"
+	"go-jwt/internal/infrastructure/repository"
+	"go-jwt/internal/usecase"
+	"os"
+	"strconv"
+	"time"
+)
+
+const auditUsage = `usage:
+  audit verify <house_id>...   check the audit chain of the houses
+  audit checkpoint             sign the last entry of every house with new entries`
+
+// Audit runs the audit command of the command line, it returns the exit code:
+// 0 when every chain is valid, 1 when one is broken or on error, 2 on a wrong usage.
+func Audit(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, auditUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "verify":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, auditUsage)
+			return 2
+		}
+		houseIDs := make([]int, len(args)-1)
+		for i, arg := range args[1:] {
+			houseID, err := strconv.Atoi(arg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "invalid house id:", arg)
+				return 2
+			}
+			houseIDs[i] = houseID
+		}
+		return auditVerify(auditService(), houseIDs)
+	case "checkpoint":
+		defer driver.CloseSqlServerDB()
+		if err := auditService().Checkpoint(time.Now()); err != nil {
+			fmt.Fprintln(os.Stderr, "audit checkpoint failed:", err.Error())
+			return 1
+		}
+		return 0
+	default:
+		fmt.Fprintln(os.Stderr, auditUsage)
+		return 2
+	}
+}
+
+func auditService() usecase.AuditUsecase {
+	db := driver.ConnectSqlServerDB()
+	driver.MigrateSqlServerDB(db)
+	return usecase.NewAuditUsecase(repository.NewAuditRepo(db))
+}
+
+// auditVerify prints the verification of every house as a line of JSON
+func auditVerify(auditService usecase.AuditUsecase, houseIDs []int) int {
+	defer driver.CloseSqlServerDB()
+
+	code := 0
+	for _, houseID := range houseIDs {
+		verification, err := auditService.Verify(houseID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "verify house %d failed: %s\n", houseID, err.Error())
+			code = 1
+			continue
+		}
+		if !verification.Valid {
+			code = 1
+		}
+		line, _ := json.Marshal(verification)
+		fmt.Println(string(line))
+	}
+	return code
+}
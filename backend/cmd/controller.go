@@ -1,34 +1,108 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"go-jwt/internal/blobstore"
+	"go-jwt/internal/config"
 	"go-jwt/internal/controller"
 	"go-jwt/internal/infrastructure/driver"
 	"go-jwt/internal/infrastructure/repository"
 	"go-jwt/internal/middleware"
+	"go-jwt/internal/scheduler"
 	"go-jwt/internal/usecase"
+	external "go-jwt/internal/usecase/external"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func (s server) SetupControllers() {
+// SetupControllers also starts the background workers, they stop when ctx is done
+func (s server) SetupControllers(ctx context.Context) {
+
+	// ClientIP only reads X-Forwarded-For from these proxies, otherwise a client could pick the IP its rate
+	// limits and lockouts are counted on
+	if err := s.router.SetTrustedProxies(config.StringList("TRUSTED_PROXIES")); err != nil {
+		panic(err)
+	}
 
 	s.router.Use(gin.Logger())
 	s.router.Use(gin.Recovery())
 	s.router.Use(middleware.CORS())
 
 	db := driver.ConnectSqlServerDB()
+	driver.MigrateSqlServerDB(db)
 
 	// init repository
 	userRepo := repository.NewUserRepo(db)
 	deviceRepo := repository.NewDeviceRepo(db)
+	scheduleRepo := repository.NewScheduleRepo(db)
+	commandRepo := repository.NewCommandRepo(db)
+	credentialRepo := repository.NewCredentialRepo(db)
+	deviceTypeRepo := repository.NewDeviceTypeRepo(db)
+	roomRepo := repository.NewRoomRepo(db)
+	auditRepo := repository.NewAuditRepo(db)
+	lockoutRepo := repository.NewLockoutRepo(db)
+
+	// external services and storage
+	faceProvider, err := external.NewFaceProvider(config.String("FACE_PROVIDER", "http"))
+	if err != nil {
+		panic(err)
+	}
+	if err := usecase.CheckFaceConfig(); err != nil {
+		panic(err)
+	}
+	blobStore, err := blobstore.New(config.String("BLOB_STORE", "local"), config.String("BLOB_STORE_DIR", "data/blobs"))
+	if err != nil {
+		panic(err)
+	}
 
 	// init usecase
-	userUsecase := usecase.NewUserUsecase(userRepo)
-	deviceUsecase := usecase.NewDeviceUsecase(deviceRepo)
+	auditUsecase := usecase.NewAuditUsecase(auditRepo)
+	deviceTypeUsecase := usecase.NewDeviceTypeUsecase(deviceTypeRepo)
+	commandUsecase := usecase.NewCommandUsecase(commandRepo, deviceTypeUsecase, auditUsecase)
+	userUsecase := usecase.NewUserUsecase(userRepo, commandUsecase, deviceTypeUsecase)
+	scheduleUsecase := usecase.NewScheduleUsecase(scheduleRepo, userRepo, commandUsecase)
+	lockoutUsecase := usecase.NewLockoutUsecase(userRepo, deviceRepo, lockoutRepo)
+	monitorUsecase := usecase.NewMonitorUsecase(deviceRepo, userRepo, deviceTypeUsecase)
+	deviceUsecase := usecase.NewDeviceUsecase(deviceRepo, commandUsecase, deviceTypeUsecase, monitorUsecase, faceProvider, blobStore, auditUsecase)
+	credentialUsecase := usecase.NewCredentialUsecase(credentialRepo, monitorUsecase, auditUsecase)
+	roomUsecase := usecase.NewRoomUsecase(roomRepo, commandUsecase, deviceTypeUsecase)
 
 	// init controller
-	controller.SetupUserRoutes(s.router, userUsecase)
-	controller.SetupDeviceRoutes(s.router, deviceUsecase)
+	// every /houses/:id route is only for the users of the house
+	houseMember := middleware.HouseMemberMiddleware(userUsecase.IsHouseMember)
+	controller.SetupUserRoutes(s.router, userUsecase, lockoutUsecase)
+	controller.SetupDeviceRoutes(s.router, deviceUsecase, lockoutUsecase, credentialUsecase)
+	controller.SetupScheduleRoutes(s.router, houseMember, scheduleUsecase)
+	controller.SetupSettingRoutes(s.router, houseMember, userUsecase)
+	controller.SetupCommandRoutes(s.router, houseMember, commandUsecase)
+	controller.SetupFaceRoutes(s.router, houseMember, deviceUsecase)
+	controller.SetupLockoutRoutes(s.router, lockoutUsecase)
+	controller.SetupSnapshotRoutes(s.router, houseMember, deviceUsecase)
+	controller.SetupCameraRoutes(s.router, houseMember, deviceUsecase)
+	controller.SetupDeviceKeyRoutes(s.router, houseMember, credentialUsecase)
+	controller.SetupDeviceTypeRoutes(s.router, houseMember, deviceTypeUsecase, deviceUsecase)
+	controller.SetupDashboardRoutes(s.router, houseMember, userUsecase)
+	controller.SetupRoomRoutes(s.router, houseMember, roomUsecase)
+	controller.SetupActivityRoutes(s.router, houseMember, userUsecase)
+	controller.SetupAuditRoutes(s.router, houseMember, auditUsecase)
+	controller.SetupNotificationRoutes(s.router, userUsecase)
+
+	// background workers
+	scheduler.Start(ctx, scheduleUsecase, config.Duration("SCHEDULER_INTERVAL", 30*time.Second))
+	scheduler.Every(ctx, config.Duration("COMMAND_OUTBOX_INTERVAL", 2*time.Second), commandUsecase.ProcessOutbox)
+	scheduler.Every(ctx, 10*time.Minute, lockoutUsecase.Prune)
+	scheduler.Every(ctx, time.Hour, deviceUsecase.PruneSnapshots)
+	scheduler.Every(ctx, config.Duration("DEVICE_MONITOR_INTERVAL", time.Minute), monitorUsecase.CheckOffline)
+	// the checkpoints are signed, there are none without a key
+	if config.String("AUDIT_SIGNING_KEY", "") != "" {
+		scheduler.Every(ctx, config.Duration("AUDIT_CHECKPOINT_INTERVAL", time.Hour), func(now time.Time) {
+			if err := auditUsecase.Checkpoint(now); err != nil {
+				fmt.Println("audit checkpoint failed:", err.Error())
+			}
+		})
+	}
 }
 
 func (s server) CloseSqlServerDB() {
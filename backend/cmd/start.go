@@ -13,7 +13,8 @@ import (
 func (s server) Start() {
 
 	// Set up controllers
-	s.SetupControllers()
+	workers, stopWorkers := context.WithCancel(context.Background())
+	s.SetupControllers(workers)
 
 	// start server
 	httpServer := &http.Server{
@@ -31,6 +32,7 @@ func (s server) Start() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	stopWorkers()
 	//close database connection
 	s.CloseSqlServerDB()
 	log.Println("Shutdown Server ...")
@@ -24,6 +24,11 @@ require (
 	golang.org/x/text v0.14.0 // indirect
 )
 
+require (
+	gorm.io/driver/sqlserver v1.5.3
+	gorm.io/gorm v1.25.9
+)
+
 require (
 	github.com/bytedance/sonic v1.9.1 // indirect
 	github.com/chenzhuoyu/base64x v0.0.0-20221115062448-fe3a3abad311 // indirect
@@ -52,6 +57,4 @@ require (
 	golang.org/x/sys v0.18.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
-	gorm.io/driver/sqlserver v1.5.3 // indirect
-	gorm.io/gorm v1.25.9 // indirect
 )
@@ -0,0 +1,170 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Policy is when a reading of a sensor is suspicious
+type Policy struct {
+	Window     int     // readings kept per sensor for the mean and the standard deviation
+	MinSamples int     // readings needed before the z-score and spike checks start
+	ZScore     float64 // a reading further than this many standard deviations from the mean is an outlier
+	MinStd     float64 // the smallest standard deviation used, a sensor that barely moves would flag every change
+	Flatline   int     // this many identical readings in a row means the sensor is stuck, 0 to never check
+	Accept     int     // this many outliers in a row are a real change, the window starts again from them
+}
+
+const (
+	ReasonOutlier  = "outlier"
+	ReasonSpike    = "spike"
+	ReasonFlatline = "flatline"
+)
+
+// Result of the check of a reading, Reason is empty for a normal reading
+type Result struct {
+	Anomaly bool
+	Reason  string
+	Detail  string
+}
+
+// Detector keeps a rolling window per sensor in memory, it is empty again after a restart.
+// The flagged readings are left out of the window, so a faulty reading does not move the mean.
+type Detector struct {
+	mu      sync.Mutex
+	policy  Policy
+	sensors map[int]*window
+}
+
+type window struct {
+	values   []float64 // oldest first
+	deltas   []float64 // the change between two normal readings, for the spikes
+	last     float64
+	repeated int
+	outliers []float64
+}
+
+func (w *window) clone() *window {
+	return &window{
+		values:   append([]float64(nil), w.values...),
+		deltas:   append([]float64(nil), w.deltas...),
+		last:     w.last,
+		repeated: w.repeated,
+		outliers: append([]float64(nil), w.outliers...),
+	}
+}
+
+// Pending is the check of readings of a sensor, the window it leads to is kept by Commit
+type Pending struct {
+	Results  []Result
+	sensorID int
+	window   *window
+}
+
+func New(policy Policy) *Detector {
+	return &Detector{
+		policy:  policy,
+		sensors: make(map[int]*window),
+	}
+}
+
+// Check returns whether each reading of the sensor is suspicious, in order. The window of the sensor only
+// moves on Commit, so readings that are not stored in the end are not learned.
+func (d *Detector) Check(sensorID int, values ...float64) *Pending {
+	d.mu.Lock()
+	w := &window{}
+	if current, ok := d.sensors[sensorID]; ok {
+		w = current.clone()
+	}
+	d.mu.Unlock()
+
+	pending := &Pending{Results: make([]Result, len(values)), sensorID: sensorID, window: w}
+	for i, value := range values {
+		pending.Results[i] = d.check(w, value)
+	}
+	return pending
+}
+
+// Commit keeps the window of the checked readings. Of two checks of a sensor at the same time the last
+// commit wins.
+func (d *Detector) Commit(pending *Pending) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sensors[pending.sensorID] = pending.window
+}
+
+// check adds the reading to the window when it is not suspicious
+func (d *Detector) check(w *window, value float64) Result {
+	if len(w.values) > 0 && value == w.last {
+		w.repeated++
+	} else {
+		w.repeated = 1
+	}
+	if d.policy.Flatline > 0 && w.repeated >= d.policy.Flatline {
+		// the value itself can be right, it is still kept so the window follows the sensor
+		d.add(w, value)
+		return Result{Anomaly: true, Reason: ReasonFlatline, Detail: fmt.Sprintf("the same value %g %d times in a row", value, w.repeated)}
+	}
+
+	if len(w.values) >= d.policy.MinSamples {
+		mean, std := meanStd(w.values)
+		if z := math.Abs(value-mean) / math.Max(std, d.policy.MinStd); z > d.policy.ZScore {
+			return d.outlier(w, value, Result{Anomaly: true, Reason: ReasonOutlier,
+				Detail: fmt.Sprintf("%g is %.1f standard deviations from the mean %.2f", value, z, mean)})
+		}
+		// a jump much larger than the usual change between two readings, even when the value stays in range
+		mean, std = meanStd(w.deltas)
+		if z := (math.Abs(value-w.last) - mean) / math.Max(std, d.policy.MinStd); z > d.policy.ZScore {
+			return d.outlier(w, value, Result{Anomaly: true, Reason: ReasonSpike,
+				Detail: fmt.Sprintf("jump of %g from %g", value-w.last, w.last)})
+		}
+	}
+
+	w.outliers = w.outliers[:0]
+	d.add(w, value)
+	return Result{}
+}
+
+// outlier keeps the outliers in a row, enough of them start the window again
+func (d *Detector) outlier(w *window, value float64, result Result) Result {
+	w.outliers = append(w.outliers, value)
+	if d.policy.Accept > 0 && len(w.outliers) >= d.policy.Accept {
+		w.values, w.deltas = nil, nil
+		for _, outlier := range w.outliers {
+			d.add(w, outlier)
+		}
+		w.outliers = w.outliers[:0]
+	}
+	return result
+}
+
+func (d *Detector) add(w *window, value float64) {
+	if len(w.values) > 0 {
+		// the change between two readings is always positive so a steady rise is not a spike
+		w.deltas = push(w.deltas, math.Abs(value-w.last), d.policy.Window)
+	}
+	w.values = push(w.values, value, d.policy.Window)
+	w.last = value
+}
+
+func push(values []float64, value float64, max int) []float64 {
+	values = append(values, value)
+	if len(values) > max {
+		values = values[len(values)-max:]
+	}
+	return values
+}
+
+func meanStd(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
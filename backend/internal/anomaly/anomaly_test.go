@@ -0,0 +1,96 @@
+package anomaly
+
+import "testing"
+
+var policy = Policy{Window: 30, MinSamples: 10, ZScore: 4, MinStd: 0.5, Flatline: 5, Accept: 3}
+
+// steady returns n readings that go up and down around 25
+func steady(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = 25 + float64(i%3)*0.4
+	}
+	return values
+}
+
+// learned is a detector that learned the readings
+func learned(values []float64) *Detector {
+	d := New(policy)
+	d.Commit(d.Check(1, values...))
+	return d
+}
+
+func TestCheckNormal(t *testing.T) {
+	pending := New(policy).Check(1, steady(20)...)
+	for i, result := range pending.Results {
+		if result.Anomaly {
+			t.Errorf("reading %d: %+v", i+1, result)
+		}
+	}
+}
+
+func TestCheckOutlier(t *testing.T) {
+	d := learned(steady(20))
+	result := d.Check(1, 90).Results[0]
+	if !result.Anomaly || result.Reason != ReasonOutlier {
+		t.Errorf("result = %+v, want an outlier", result)
+	}
+}
+
+func TestCheckNotBeforeMinSamples(t *testing.T) {
+	d := learned(steady(policy.MinSamples - 1))
+	if result := d.Check(1, 90).Results[0]; result.Anomaly {
+		t.Errorf("result = %+v before %d samples", result, policy.MinSamples)
+	}
+}
+
+func TestCheckSpike(t *testing.T) {
+	// a slow rise, then a jump that is still close enough to the mean of the window
+	values := make([]float64, 30)
+	for i := range values {
+		values[i] = 20 + float64(i)*0.1
+	}
+	d := New(Policy{Window: 30, MinSamples: 10, ZScore: 4, MinStd: 0.1, Flatline: 0, Accept: 3})
+	d.Commit(d.Check(1, values...))
+	result := d.Check(1, 20.5).Results[0]
+	if !result.Anomaly || result.Reason != ReasonSpike {
+		t.Errorf("result = %+v, want a spike", result)
+	}
+}
+
+func TestCheckFlatline(t *testing.T) {
+	results := New(policy).Check(1, 25, 25, 25, 25, 25, 25).Results
+	for i, result := range results {
+		want := i+1 >= policy.Flatline
+		if result.Anomaly != want || (want && result.Reason != ReasonFlatline) {
+			t.Errorf("reading %d: %+v, want anomaly %v", i+1, result, want)
+		}
+	}
+}
+
+func TestCheckAcceptsALastingChange(t *testing.T) {
+	d := learned(steady(20))
+	results := d.Check(1, 60, 60.4, 60.2, 60.3).Results
+	for i, result := range results[:policy.Accept] {
+		if !result.Anomaly {
+			t.Errorf("reading %d is not an outlier", i+1)
+		}
+	}
+	// the window started again from the outliers, the new level is normal
+	if results[3].Anomaly {
+		t.Errorf("reading after %d outliers: %+v", policy.Accept, results[3])
+	}
+}
+
+func TestCheckWithoutCommit(t *testing.T) {
+	d := learned(steady(20))
+	// readings that are never stored are not learned
+	d.Check(1, 60, 60.4, 60.2, 60.3)
+	if result := d.Check(1, 60).Results[0]; !result.Anomaly {
+		t.Error("the readings of a check that was not committed were learned")
+	}
+	// the sensors have their own window
+	if result := d.Check(2, 60).Results[0]; result.Anomaly {
+		t.Errorf("another sensor: %+v", result)
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the environment variable key or def when it is not set
+func String(key string, def string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return def
+}
+
+func Int(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+func Float(key string, def float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+func Bool(key string, def bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Duration accepts values like "30s" or "5m"
+func Duration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// StringList reads a comma separated list like "a, b", the empty values are skipped
+func StringList(key string) []string {
+	var values []string
+	for _, field := range strings.Split(os.Getenv(key), ",") {
+		if value := strings.TrimSpace(field); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// IntList reads a comma separated list like "1,2,3", the values that are not numbers are skipped
+func IntList(key string) []int {
+	var values []int
+	for _, field := range strings.Split(os.Getenv(key), ",") {
+		if value, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
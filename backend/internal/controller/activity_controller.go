@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ActivityController struct {
+	userService     usecase.UserUsecase
+	NewHouseRequest func() request.HouseRequest
+}
+
+func SetupActivityRoutes(router *gin.Engine, houseMember gin.HandlerFunc, userService usecase.UserUsecase) {
+	activityController := ActivityController{
+		userService:     userService,
+		NewHouseRequest: request.NewHouseRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/activity", activityController.getActivityLog)
+	}
+}
+
+// GET /houses/1/activity?q=door&limit=50, the newest entries first.
+// It is filtered by who did it with ?actor=user&actor_id=3, by ?source=app and by ?event=face.rejected.
+// The next page is read with ?cursor= set to the next_cursor of the page, with the same filters.
+func (h ActivityController) getActivityLog(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := request.GetActivityFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.userService.GetActivityLog(houseID, filter)
+	if err != nil {
+		fmt.Println("get activity log failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get activity log failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditController struct {
+	auditService    usecase.AuditUsecase
+	NewHouseRequest func() request.HouseRequest
+}
+
+func SetupAuditRoutes(router *gin.Engine, houseMember gin.HandlerFunc, auditService usecase.AuditUsecase) {
+	auditController := AuditController{
+		auditService:    auditService,
+		NewHouseRequest: request.NewHouseRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/audit", auditController.getAuditEntries)
+		houseRoutes.GET("/:id/audit/verify", auditController.verifyAudit)
+		houseRoutes.GET("/:id/audit/checkpoints", auditController.getCheckpoints)
+	}
+}
+
+// GET /houses/1/audit?after=120&limit=100, the entries in the order of the chain after the seq
+func (h AuditController) getAuditEntries(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	after, err := strconv.ParseInt(ctx.DefaultQuery("after", "0"), 10, 64)
+	if err != nil || after < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'after' value. Must be a seq of the audit log"})
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 500 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'limit' value. Must be between 1 and 500"})
+		return
+	}
+
+	entries, err := h.auditService.GetEntries(houseID, after, limit)
+	if err != nil {
+		fmt.Println("get audit entries failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get audit entries failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// GET /houses/1/audit/verify, 200 with "valid": false when the chain is broken
+func (h AuditController) verifyAudit(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	verification, err := h.auditService.Verify(houseID)
+	if err != nil {
+		fmt.Println("verify audit log failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "verify audit log failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verification)
+}
+
+func (h AuditController) getCheckpoints(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	checkpoints, err := h.auditService.GetCheckpoints(houseID)
+	if err != nil {
+		fmt.Println("get audit checkpoints failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get audit checkpoints failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, checkpoints)
+}
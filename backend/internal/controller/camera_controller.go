@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/entity"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CameraController struct {
+	deviceService   usecase.DeviceUsecase
+	NewHouseRequest func() request.HouseRequest
+}
+
+func SetupCameraRoutes(router *gin.Engine, houseMember gin.HandlerFunc, deviceService usecase.DeviceUsecase) {
+	cameraController := CameraController{
+		deviceService:   deviceService,
+		NewHouseRequest: request.NewHouseRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/cameras", cameraController.getCameras)
+		houseRoutes.POST("/:id/cameras", cameraController.registerCamera)
+		houseRoutes.PUT("/:id/cameras/:device_id", cameraController.updateCamera)
+	}
+}
+
+// GET /houses/1/cameras
+func (h CameraController) getCameras(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cameras, err := h.deviceService.GetCameras(houseID)
+	if err != nil {
+		fmt.Println("get cameras failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get cameras failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cameras)
+}
+
+// POST /houses/1/cameras {"name": "Front door", "door_id": 2}
+// The key of the camera is only in this response, it has to be copied to the camera.
+// A new key is issued with POST /houses/1/devices/3/keys.
+func (h CameraController) registerCamera(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor, err := request.GetActor(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var camera entity.Camera
+	if err := ctx.ShouldBindJSON(&camera); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	camera.Device_id = 0
+	camera.House_id = houseID
+
+	key, credential, err := h.deviceService.RegisterCamera(&camera, actor)
+	if err != nil {
+		fmt.Println("register camera failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "register camera failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"camera": camera, "key": key, "credential": credential})
+}
+
+// PUT /houses/1/cameras/3 {"name": "Front door", "door_id": 2}
+func (h CameraController) updateCamera(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deviceID, err := request.GetIntParam(ctx, "device_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var camera entity.Camera
+	if err := ctx.ShouldBindJSON(&camera); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	camera.Device_id = deviceID
+	camera.House_id = houseID
+
+	if err := h.deviceService.UpdateCamera(&camera); err != nil {
+		fmt.Println("update camera failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "update camera failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Camera updated successfully"})
+}
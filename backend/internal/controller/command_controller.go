@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/entity"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommandController struct {
+	commandService  usecase.CommandUsecase
+	NewHouseRequest func() request.HouseRequest
+}
+
+func SetupCommandRoutes(router *gin.Engine, houseMember gin.HandlerFunc, commandService usecase.CommandUsecase) {
+	commandController := CommandController{
+		commandService:  commandService,
+		NewHouseRequest: request.NewHouseRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/commands", commandController.getCommands)
+		houseRoutes.GET("/:id/commands/:command_id", commandController.getCommand)
+	}
+}
+
+// GET /houses/1/commands?status=failed&limit=20
+func (h CommandController) getCommands(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	roomID, err := request.GetRoomFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := ctx.Query("status")
+	switch status {
+	case "", entity.CommandQueued, entity.CommandSent, entity.CommandAcknowledged, entity.CommandFailed:
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'status' value"})
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'limit' value. Must be between 1 and 500"})
+		return
+	}
+
+	commands, err := h.commandService.GetCommandsByHouseID(houseID, status, roomID, limit)
+	if err != nil {
+		fmt.Println("get commands failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get commands failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, commands)
+}
+
+func (h CommandController) getCommand(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	commandID, err := request.GetIntParam(ctx, "command_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	command, err := h.commandService.GetCommandByID(houseID, commandID)
+	if err != nil {
+		fmt.Println("get command failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get command failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, command)
+}
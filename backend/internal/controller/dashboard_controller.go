@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/entity"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardController struct {
+	userService     usecase.UserUsecase
+	NewHouseRequest func() request.HouseRequest
+	NewUserRequest  func() request.UserRequest
+}
+
+func SetupDashboardRoutes(router *gin.Engine, houseMember gin.HandlerFunc, userService usecase.UserUsecase) {
+	dashboardController := DashboardController{
+		userService:     userService,
+		NewHouseRequest: request.NewHouseRequest,
+		NewUserRequest:  request.NewUserRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/dashboard", dashboardController.getDashboard)
+		houseRoutes.GET("/:id/dashboard/layout", dashboardController.getLayout)
+		houseRoutes.PUT("/:id/dashboard/layout", dashboardController.saveLayout)
+		houseRoutes.DELETE("/:id/dashboard/layout", dashboardController.resetLayout)
+	}
+}
+
+// userHouse reads the user of the token and the house of the url, it responds itself on error
+func (h DashboardController) userHouse(ctx *gin.Context) (int, int, bool) {
+	userID, err := h.NewUserRequest().GetUserIDFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	return userID, houseID, true
+}
+
+// GET /houses/1/dashboard, the data of the devices in the layout of the user
+func (h DashboardController) getDashboard(ctx *gin.Context) {
+	userID, houseID, ok := h.userHouse(ctx)
+	if !ok {
+		return
+	}
+
+	dashboard, err := h.userService.GetHouseDashboard(userID, houseID)
+	if err != nil {
+		fmt.Println("get dashboard failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get dashboard failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dashboard)
+}
+
+func (h DashboardController) getLayout(ctx *gin.Context) {
+	userID, houseID, ok := h.userHouse(ctx)
+	if !ok {
+		return
+	}
+
+	layout, err := h.userService.GetDashboardLayout(userID, houseID)
+	if err != nil {
+		fmt.Println("get dashboard layout failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get dashboard layout failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, layout)
+}
+
+// PUT /houses/1/dashboard/layout {"groups": [{"name": "Kitchen", "widgets": [{"device_id": 3, "widget": "toggle", "label": "Ceiling"}]}]}
+func (h DashboardController) saveLayout(ctx *gin.Context) {
+	userID, houseID, ok := h.userHouse(ctx)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Groups []entity.DashboardGroup `json:"groups" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	layout := &entity.DashboardLayout{User_id: userID, House_id: houseID, Groups: body.Groups}
+	if err := h.userService.SaveDashboardLayout(layout); err != nil {
+		fmt.Println("save dashboard layout failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "save dashboard layout failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, layout)
+}
+
+// DELETE /houses/1/dashboard/layout, back to the default layout
+func (h DashboardController) resetLayout(ctx *gin.Context) {
+	userID, houseID, ok := h.userHouse(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.ResetDashboardLayout(userID, houseID); err != nil {
+		fmt.Println("reset dashboard layout failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "reset dashboard layout failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Dashboard layout reset successfully"})
+}
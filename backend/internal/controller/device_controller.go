@@ -1,45 +1,61 @@
 package controller
 
 import (
-	"bytes"
 	"encoding/json"
+	"fmt"
 	"go-jwt/internal/entity"
 	"go-jwt/internal/middleware"
 	request "go-jwt/internal/request"
 	usecase "go-jwt/internal/usecase"
 	"io"
-	"mime/multipart"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type DeviceController struct {
 	deviceService    usecase.DeviceUsecase
+	lockoutService   usecase.LockoutUsecase
 	NewDeviceRequest func() request.DeviceRequest
 }
 
-func SetupDeviceRoutes(router *gin.Engine, deviceService usecase.DeviceUsecase) {
+func SetupDeviceRoutes(router *gin.Engine, deviceService usecase.DeviceUsecase, lockoutService usecase.LockoutUsecase, credentialService usecase.CredentialUsecase) {
 	deviceController := DeviceController{
 		deviceService:    deviceService,
+		lockoutService:   lockoutService,
 		NewDeviceRequest: request.NewDeviceRequest,
 	}
 
-	deviceRoutes := router.Group("/devices")
+	// every device sends its key, the house and the device come from the key
+	deviceRoutes := router.Group("/devices").Use(middleware.DeviceAuthMiddleware(credentialService.Authenticate))
 	{
 		deviceRoutes.Use(middleware.CORS())
 		deviceRoutes.POST("/updateTemperature", deviceController.UpdateTemperature)
 		deviceRoutes.POST("/updateHumidity", deviceController.UpdateHumidity)
 		deviceRoutes.POST("/updateFanSpeed", deviceController.UpdateFanSpeed)
 		deviceRoutes.GET("/update", deviceController.UpdateDevice)
+		deviceRoutes.POST("/telemetry", deviceController.RecordTelemetry)
 		deviceRoutes.POST("/setFace", deviceController.UploadImage)
 		deviceRoutes.POST("/verifyFace", deviceController.VerifyFace)
 	}
+
+	// the health probe of the monitoring has no key, it tells nothing about a house. It is out of /devices,
+	// where every route needs the key of a device.
+	healthRoutes := router.Group("/health")
+	{
+		healthRoutes.Use(middleware.CORS())
+		healthRoutes.GET("/faceService", deviceController.FaceServiceHealth)
+	}
 }
 
 func (h DeviceController) UpdateTemperature(ctx *gin.Context) {
-	// Read request body//
+	device, err := h.NewDeviceRequest().GetDevice(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Read request body
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
@@ -64,8 +80,8 @@ func (h DeviceController) UpdateTemperature(ctx *gin.Context) {
 	}
 
 	// Update the temperature
-	if err := h.deviceService.UpdateTemperature(1, temp); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update temperature"})
+	if err := h.deviceService.UpdateTemperature(device, temp); err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to update temperature", "detail": err.Error()})
 		return
 	}
 	// Respond with success message
@@ -74,6 +90,12 @@ func (h DeviceController) UpdateTemperature(ctx *gin.Context) {
 }
 
 func (h DeviceController) UpdateHumidity(ctx *gin.Context) {
+	device, err := h.NewDeviceRequest().GetDevice(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
 	// Read request body
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
@@ -98,8 +120,8 @@ func (h DeviceController) UpdateHumidity(ctx *gin.Context) {
 	}
 
 	// Update the humidity
-	if err := h.deviceService.UpdateHumidity(1, humid); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update humidity"})
+	if err := h.deviceService.UpdateHumidity(device, humid); err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to update humidity", "detail": err.Error()})
 		return
 	}
 
@@ -108,6 +130,12 @@ func (h DeviceController) UpdateHumidity(ctx *gin.Context) {
 }
 
 func (h DeviceController) UpdateFanSpeed(ctx *gin.Context) {
+	device, err := h.NewDeviceRequest().GetDevice(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
 	// Read request body
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
@@ -125,15 +153,16 @@ func (h DeviceController) UpdateFanSpeed(ctx *gin.Context) {
 	}
 
 	// Extract "speed" value from the JSON
-	speed, ok := data["speed"].(int)
-	if !ok {
+	// JSON numbers are decoded as float64
+	speed, ok := data["speed"].(float64)
+	if !ok || speed != float64(int(speed)) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or missing 'speed' value"})
 		return
 	}
 
 	// Update the fan speed
-	if err := h.deviceService.UpdateFanSpeed(1, speed); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update fan speed"})
+	if err := h.deviceService.UpdateFanSpeed(device, int(speed)); err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to update fan speed", "detail": err.Error()})
 		return
 	}
 
@@ -142,16 +171,19 @@ func (h DeviceController) UpdateFanSpeed(ctx *gin.Context) {
 }
 
 func (h DeviceController) UpdateDevice(ctx *gin.Context) {
-	// Extract the query parameters from the request
-	houseID, deviceID, deviceType, data, state, err := h.NewDeviceRequest().GetDataFromDeviceRequest(ctx)
+	request := h.NewDeviceRequest()
+	device, err := request.GetDevice(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse query parameters"})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	// Extract the query parameters from the request
+	data, state := request.GetDataFromDeviceRequest(ctx)
+
 	// Update the device
-	if err := h.deviceService.UpdateDevice(houseID, deviceID, deviceType, data, state); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update device"})
+	if err := h.deviceService.UpdateDevice(device, data, state); err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to update device", "detail": err.Error()})
 		return
 	}
 
@@ -159,58 +191,69 @@ func (h DeviceController) UpdateDevice(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "Device updated successfully"})
 }
 
-func (h DeviceController) UploadImage(ctx *gin.Context) {
+// POST /devices/telemetry [{"data": 30, "state": true, "time": "2024-05-01T10:00:00Z"}, ...]
+// The readings can also be sent one JSON object per line.
+func (h DeviceController) RecordTelemetry(ctx *gin.Context) {
+	request := h.NewDeviceRequest()
+	device, err := request.GetDevice(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	// Extract the image file from the request
-	file, err := ctx.FormFile("img")
+	readings, err := request.GetReadings(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image file"})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// Create a new form data
-	formData := new(bytes.Buffer)
-	writer := multipart.NewWriter(formData)
-	// send in form-data with the file
-	part, err := writer.CreateFormFile("img", file.Filename)
+	recorded, err := h.deviceService.RecordTelemetry(device, readings)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create form file"})
+		fmt.Println("record telemetry failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to record telemetry", "detail": err.Error()})
 		return
 	}
 
-	// Open the file
-	fileHandle, err := file.Open()
+	ctx.JSON(http.StatusOK, gin.H{"message": "Telemetry recorded successfully", "received": len(readings), "recorded": recorded})
+}
+
+// POST /devices/setFace with the key of a camera, the face is enrolled in the house of the camera
+func (h DeviceController) UploadImage(ctx *gin.Context) {
+	request := h.NewDeviceRequest()
+
+	camera, err := request.GetDevice(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to open file"})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if camera.Type != "Camera" {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": entity.ErrNotACamera.Error()})
 		return
 	}
-	defer fileHandle.Close()
 
-	// Copy the file to the form data
-	_, err = io.Copy(part, fileHandle)
+	// the face can be enrolled for a person of the house, or stay anonymous
+	personID, err := request.GetPersonID(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to copy file to form data"})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	writer.Close()
-	// Define struct to unmarshal JSON into
-	var data map[string]interface{}
-	err = h.deviceService.EncodeFace(1, formData, writer.FormDataContentType(), &data)
+
+	// Extract the image file from the request
+	image, filename, contentType, err := request.GetImage(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send image to face recognition service"})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// Extract "face_encoding" value from the JSON
-	faceEncode, ok := data["face_encoding"].(string)
-	if !ok {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or missing 'face_encoding' value"})
+	faceEncode, err := h.deviceService.EncodeImage(camera.House_id, image, filename, contentType)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to encode the face", "detail": err.Error()})
 		return
 	}
 
 	// Update the face encodings
-	if err := h.deviceService.UpdateFaceEncodings(1, faceEncode); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update face encodings"})
+	if err := h.deviceService.UpdateFaceEncodings(camera.House_id, personID, faceEncode); err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to update face encodings", "detail": err.Error()})
 		return
 	}
 
@@ -218,97 +261,54 @@ func (h DeviceController) UploadImage(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "Image uploaded successfully"})
 }
 
+// POST /devices/verifyFace with the key of the camera and the image in the "img" form field.
+// The door of the camera only opens for a face enrolled in its house.
 func (h DeviceController) VerifyFace(ctx *gin.Context) {
+	request := h.NewDeviceRequest()
 
-	// Extract the image file from the request
-	file, err := ctx.FormFile("img")
+	camera, err := request.GetDevice(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image file"})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
+	cameraID := camera.ID
 
-	// Create a new form data
-	formData := new(bytes.Buffer)
-	writer := multipart.NewWriter(formData)
-	// send in form-data with the file
-	part, err := writer.CreateFormFile("img", file.Filename)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create form file"})
+	if err := h.lockoutService.CheckFace(cameraID, ctx.ClientIP()); err != nil {
+		setRetryAfter(ctx, err)
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": "Failed to verify face", "detail": err.Error()})
 		return
 	}
 
-	// Open the file
-	fileHandle, err := file.Open()
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to open file"})
-		return
-	}
-	defer fileHandle.Close()
-
-	// Copy the file to the form data
-	_, err = io.Copy(part, fileHandle)
+	// Extract the image file from the request
+	image, filename, contentType, err := request.GetImage(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to copy file to form data"})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// add "encoding_array" to the form data by taking the value from db
-	face_encodings, err := h.deviceService.GetFaceEncoding(1)
+	match, command, err := h.deviceService.RecognizeFace(cameraID, image, filename, contentType)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get face encodings from database"})
+		fmt.Println("verify face failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to verify face", "detail": err.Error()})
 		return
 	}
 
-	// Add the face encodings to "encoding_array" key as an array of strings
-	for _, encoding := range face_encodings {
-		if err := writer.WriteField("encoding_array", encoding); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to add face encodings to form data"})
-			return
-		}
-	}
-
-	// 	if there is not a face
-	// {
-	//   "error": "No face found in the image"
-	// }
-	// if there is a face.
-	// {
-	//   "is_match": true
-	// }
-
-	var data map[string]interface{}
-	writer.Close()
-	err = h.deviceService.VerifyFace(1, formData, writer.FormDataContentType(), &data)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send image to face recognition service"})
-		return
-	}
-
-	// Extract "is_match" value from the JSON
-	isMatch, ok := data["is_match"].(bool)
-	if !ok {
-		// take the error message
-		errorMessage, ok := data["error"].(string)
-		if !ok {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Something wrong with the response"})
-			return
-		}
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": errorMessage})
+	if !match.Matched {
+		h.lockoutService.FaceFailed(cameraID, ctx.ClientIP())
+		ctx.JSON(http.StatusOK, gin.H{"message": "Face not recognized", "is_match": false, "match": match})
 		return
 	}
+	h.lockoutService.FaceSucceeded(cameraID, ctx.ClientIP())
+	ctx.JSON(http.StatusOK, gin.H{"message": "Face verified successfully", "is_match": true, "match": match, "command": command})
+}
 
-	err = h.deviceService.OpenDoorAfterFaceVerified(1)
+func (h DeviceController) FaceServiceHealth(ctx *gin.Context) {
+	provider, err := h.deviceService.FaceProviderHealth()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open the door"})
+		fmt.Println("face provider health failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"provider": provider, "healthy": false, "error": err.Error()})
 		return
 	}
 
-	_ = h.deviceService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Door",
-		Time:          time.Now(),
-		Type_of_event: "Open the door after face verified",
-	})
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "Face verified successfully", "is_match": isMatch})
+	ctx.JSON(http.StatusOK, gin.H{"provider": provider, "healthy": true})
 }
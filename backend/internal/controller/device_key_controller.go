@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceKeyController struct {
+	credentialService usecase.CredentialUsecase
+	NewHouseRequest   func() request.HouseRequest
+}
+
+func SetupDeviceKeyRoutes(router *gin.Engine, houseMember gin.HandlerFunc, credentialService usecase.CredentialUsecase) {
+	deviceKeyController := DeviceKeyController{
+		credentialService: credentialService,
+		NewHouseRequest:   request.NewHouseRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/devices/:device_id/keys", deviceKeyController.getKeys)
+		houseRoutes.POST("/:id/devices/:device_id/keys", deviceKeyController.issueKey)
+		houseRoutes.DELETE("/:id/devices/:device_id/keys", deviceKeyController.revokeKeys)
+	}
+}
+
+// GET /houses/1/devices/3/keys only shows the start of the key and when it was last used
+func (h DeviceKeyController) getKeys(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deviceID, err := request.GetIntParam(ctx, "device_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credentials, err := h.credentialService.GetKeys(houseID, deviceID)
+	if err != nil {
+		fmt.Println("get device keys failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get device keys failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, credentials)
+}
+
+// POST /houses/1/devices/3/keys issues a new key, the previous one stops working.
+// The key is only in this response, it has to be copied to the device.
+func (h DeviceKeyController) issueKey(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deviceID, err := request.GetIntParam(ctx, "device_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, err := request.GetActor(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	key, credential, err := h.credentialService.IssueKey(houseID, deviceID, actor)
+	if err != nil {
+		fmt.Println("issue device key failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "issue device key failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"key": key, "credential": credential})
+}
+
+// DELETE /houses/1/devices/3/keys, the device is refused until it gets a new key
+func (h DeviceKeyController) revokeKeys(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deviceID, err := request.GetIntParam(ctx, "device_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, err := request.GetActor(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.credentialService.RevokeKeys(houseID, deviceID, actor); err != nil {
+		fmt.Println("revoke device keys failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "revoke device keys failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Device keys revoked successfully"})
+}
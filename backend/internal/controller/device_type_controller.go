@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceTypeController struct {
+	deviceTypeService usecase.DeviceTypeUsecase
+	deviceService     usecase.DeviceUsecase
+	NewHouseRequest   func() request.HouseRequest
+}
+
+func SetupDeviceTypeRoutes(router *gin.Engine, houseMember gin.HandlerFunc, deviceTypeService usecase.DeviceTypeUsecase, deviceService usecase.DeviceUsecase) {
+	deviceTypeController := DeviceTypeController{
+		deviceTypeService: deviceTypeService,
+		deviceService:     deviceService,
+		NewHouseRequest:   request.NewHouseRequest,
+	}
+
+	deviceTypeRoutes := router.Group("/device-types").Use(middleware.JwtAuthMiddleware())
+	{
+		deviceTypeRoutes.Use(middleware.CORS())
+		deviceTypeRoutes.GET("", deviceTypeController.getDeviceTypes)
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/devices", deviceTypeController.getDevices)
+		houseRoutes.GET("/:id/devices/:device_id/stats", deviceTypeController.getDataStats)
+	}
+}
+
+// GET /device-types, the unit, the range and the commands of every type of device
+func (h DeviceTypeController) getDeviceTypes(ctx *gin.Context) {
+	deviceTypes, err := h.deviceTypeService.GetDeviceTypes()
+	if err != nil {
+		fmt.Println("get device types failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get device types failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deviceTypes)
+}
+
+// GET /houses/1/devices?room_id=2, each device with the schema of its type
+func (h DeviceTypeController) getDevices(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	roomID, err := request.GetRoomFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	devices, err := h.deviceService.GetDevices(houseID, roomID)
+	if err != nil {
+		fmt.Println("get devices failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get devices failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, devices)
+}
+
+// GET /houses/1/devices/2/stats?from=2024-05-01T00:00:00Z&to=2024-05-02T00:00:00Z, the last 24 hours by default.
+// The readings flagged as anomalies are only counted.
+func (h DeviceTypeController) getDataStats(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deviceID, err := request.GetIntParam(ctx, "device_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to, err := request.GetTimeRange(ctx, 24*time.Hour)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.deviceService.GetDataStats(houseID, deviceID, from, to)
+	if err != nil {
+		fmt.Println("get data stats failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get data stats failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"errors"
+	"go-jwt/internal/blobstore"
+	"go-jwt/internal/entity"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errorStatus maps the errors of the usecases to the http status code of the response
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound),
+		errors.Is(err, entity.ErrScheduleNotFound),
+		errors.Is(err, entity.ErrCommandNotFound),
+		errors.Is(err, entity.ErrFacePersonNotFound),
+		errors.Is(err, entity.ErrFaceEncodingNotFound),
+		errors.Is(err, entity.ErrCameraNotFound),
+		errors.Is(err, entity.ErrLockoutNotFound),
+		errors.Is(err, entity.ErrSnapshotNotFound),
+		errors.Is(err, entity.ErrCredentialNotFound),
+		errors.Is(err, blobstore.ErrBlobNotFound),
+		errors.Is(err, entity.ErrRoomNotFound),
+		errors.Is(err, entity.ErrNotificationNotFound),
+		errors.Is(err, entity.ErrHouseSettingNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, entity.ErrInvalidDeviceKey),
+		errors.Is(err, entity.ErrTokenWithoutUser):
+		return http.StatusUnauthorized
+	case errors.Is(err, entity.ErrNotACamera),
+		errors.Is(err, entity.ErrNotHouseMember),
+		errors.Is(err, entity.ErrWrongDeviceType):
+		return http.StatusForbidden
+	case errors.Is(err, entity.ErrHouseSettingExists),
+		errors.Is(err, entity.ErrHouseSettingInUse),
+		errors.Is(err, entity.ErrRoomExists):
+		return http.StatusConflict
+	case errors.Is(err, entity.ErrImageTooLarge),
+		errors.Is(err, entity.ErrTelemetryTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, entity.ErrUnsupportedImage):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, entity.ErrInvalidImage):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, entity.ErrInvalidSchedule),
+		errors.Is(err, entity.ErrInvalidHouseSetting),
+		errors.Is(err, entity.ErrInvalidFacePerson),
+		errors.Is(err, entity.ErrNoFaceFound),
+		errors.Is(err, entity.ErrImageRequired),
+		errors.Is(err, entity.ErrInvalidCommand),
+		errors.Is(err, entity.ErrInvalidCamera),
+		errors.Is(err, entity.ErrInvalidTelemetry),
+		errors.Is(err, entity.ErrUnknownDeviceType),
+		errors.Is(err, entity.ErrInvalidDeviceData),
+		errors.Is(err, entity.ErrInvalidDashboardLayout),
+		errors.Is(err, entity.ErrInvalidRoom),
+		errors.Is(err, entity.ErrInvalidCursor),
+		errors.Is(err, entity.ErrHouseIDRequired),
+		errors.Is(err, entity.ErrHouseLocationNotSet):
+		return http.StatusBadRequest
+	case errors.Is(err, entity.ErrCircuitOpen),
+		errors.Is(err, entity.ErrServiceUnreachable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, entity.ErrServiceTimeout):
+		return http.StatusGatewayTimeout
+	}
+
+	var limitErr *entity.RateLimitError
+	if errors.As(err, &limitErr) {
+		return http.StatusTooManyRequests
+	}
+
+	var statusErr *entity.ServiceStatusError
+	if errors.As(err, &statusErr) {
+		// the service refused what we sent it, e.g. an image without a face
+		if statusErr.StatusCode < 500 {
+			return http.StatusUnprocessableEntity
+		}
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// setRetryAfter tells the client when to try again after a rate limit error
+func setRetryAfter(ctx *gin.Context, err error) {
+	var limitErr *entity.RateLimitError
+	if errors.As(err, &limitErr) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+	}
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/entity"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FaceController struct {
+	deviceService    usecase.DeviceUsecase
+	NewHouseRequest  func() request.HouseRequest
+	NewDeviceRequest func() request.DeviceRequest
+}
+
+func SetupFaceRoutes(router *gin.Engine, houseMember gin.HandlerFunc, deviceService usecase.DeviceUsecase) {
+	faceController := FaceController{
+		deviceService:    deviceService,
+		NewHouseRequest:  request.NewHouseRequest,
+		NewDeviceRequest: request.NewDeviceRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/faces/people", faceController.getFacePeople)
+		houseRoutes.POST("/:id/faces/people", faceController.createFacePerson)
+		houseRoutes.PUT("/:id/faces/people/:person_id", faceController.updateFacePerson)
+		houseRoutes.DELETE("/:id/faces/people/:person_id", faceController.deleteFacePerson)
+		houseRoutes.POST("/:id/faces/people/:person_id/encodings", faceController.enrollFace)
+		houseRoutes.PUT("/:id/faces/encodings/:encoding_id", faceController.assignFaceEncoding)
+		houseRoutes.DELETE("/:id/faces/encodings/:encoding_id", faceController.deleteFaceEncoding)
+	}
+}
+
+// GET /houses/1/faces/people
+func (h FaceController) getFacePeople(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	people, unassigned, err := h.deviceService.GetFacePeople(houseID)
+	if err != nil {
+		fmt.Println("get face people failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get face people failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"people": people, "unassigned_encodings": unassigned})
+}
+
+// POST /houses/1/faces/people {"name": "Thien", "user_id": 1}
+func (h FaceController) createFacePerson(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var person entity.FacePerson
+	if err := ctx.ShouldBindJSON(&person); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	person.ID = 0
+	person.House_id = houseID
+	person.Encodings = nil
+
+	if err := h.deviceService.CreateFacePerson(&person); err != nil {
+		fmt.Println("create face person failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "create face person failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, person)
+}
+
+// PUT /houses/1/faces/people/2 {"name": "Thien", "user_id": null}
+func (h FaceController) updateFacePerson(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	personID, err := request.GetIntParam(ctx, "person_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var person entity.FacePerson
+	if err := ctx.ShouldBindJSON(&person); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	person.ID = personID
+	person.House_id = houseID
+
+	if err := h.deviceService.UpdateFacePerson(&person); err != nil {
+		fmt.Println("update face person failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "update face person failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Person updated successfully"})
+}
+
+// DELETE /houses/1/faces/people/2 also revokes every face encoding of the person
+func (h FaceController) deleteFacePerson(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	personID, err := request.GetIntParam(ctx, "person_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.deviceService.DeleteFacePerson(houseID, personID); err != nil {
+		fmt.Println("delete face person failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "delete face person failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Person deleted successfully"})
+}
+
+// POST /houses/1/faces/people/2/encodings with the image in the "img" form field
+func (h FaceController) enrollFace(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	personID, err := request.GetIntParam(ctx, "person_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	image, filename, contentType, err := h.NewDeviceRequest().GetImage(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	faceEncode, err := h.deviceService.EncodeImage(houseID, image, filename, contentType)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": "Failed to encode the face", "detail": err.Error()})
+		return
+	}
+
+	if err := h.deviceService.UpdateFaceEncodings(houseID, &personID, faceEncode); err != nil {
+		fmt.Println("enroll face failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "enroll face failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Face enrolled successfully"})
+}
+
+// PUT /houses/1/faces/encodings/3 {"person_id": 2} moves the encoding to another person, null leaves it to nobody
+func (h FaceController) assignFaceEncoding(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	encodingID, err := request.GetIntParam(ctx, "encoding_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body struct {
+		Person_id *int `json:"person_id"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.deviceService.AssignFaceEncoding(houseID, encodingID, body.Person_id); err != nil {
+		fmt.Println("assign face encoding failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "assign face encoding failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Face encoding updated successfully"})
+}
+
+// DELETE /houses/1/faces/encodings/3
+func (h FaceController) deleteFaceEncoding(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	encodingID, err := request.GetIntParam(ctx, "encoding_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.deviceService.DeleteFaceEncoding(houseID, encodingID); err != nil {
+		fmt.Println("delete face encoding failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "delete face encoding failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Face encoding deleted successfully"})
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/middleware"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LockoutController struct {
+	lockoutService usecase.LockoutUsecase
+}
+
+func SetupLockoutRoutes(router *gin.Engine, lockoutService usecase.LockoutUsecase) {
+	lockoutController := LockoutController{
+		lockoutService: lockoutService,
+	}
+
+	adminRoutes := router.Group("/admin").Use(middleware.JwtAuthMiddleware()).Use(middleware.AdminMiddleware())
+	{
+		adminRoutes.Use(middleware.CORS())
+		adminRoutes.GET("/lockouts", lockoutController.getLockouts)
+		adminRoutes.DELETE("/lockouts", lockoutController.clearLockout)
+	}
+}
+
+func (h LockoutController) getLockouts(ctx *gin.Context) {
+	lockouts, err := h.lockoutService.GetLockouts()
+	if err != nil {
+		fmt.Println("get lockouts failed:", err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "get lockouts failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, lockouts)
+}
+
+// DELETE /admin/lockouts?key=login:user:thien
+func (h LockoutController) clearLockout(ctx *gin.Context) {
+	key := ctx.Query("key")
+	if key == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing 'key'"})
+		return
+	}
+
+	if err := h.lockoutService.ClearLockout(key); err != nil {
+		fmt.Println("clear lockout failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "clear lockout failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Lockout cleared successfully"})
+}
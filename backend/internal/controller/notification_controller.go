@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationController struct {
+	userService    usecase.UserUsecase
+	NewUserRequest func() request.UserRequest
+}
+
+// SetupNotificationRoutes sets the routes of the notifications of the user of the token
+func SetupNotificationRoutes(router *gin.Engine, userService usecase.UserUsecase) {
+	notificationController := NotificationController{
+		userService:    userService,
+		NewUserRequest: request.NewUserRequest,
+	}
+
+	userRoutes := router.Group("/users").Use(middleware.JwtAuthMiddleware())
+	{
+		userRoutes.Use(middleware.CORS())
+		userRoutes.GET("/me/notifications", notificationController.getNotifications)
+		userRoutes.GET("/me/notifications/unread_count", notificationController.countUnread)
+		userRoutes.POST("/me/notifications/read_all", notificationController.markAllRead)
+		userRoutes.POST("/me/notifications/:notification_id/read", notificationController.markRead)
+		userRoutes.DELETE("/me/notifications/:notification_id/read", notificationController.markUnread)
+		userRoutes.POST("/me/notifications/:notification_id/archive", notificationController.archive)
+		userRoutes.DELETE("/me/notifications/:notification_id", notificationController.deleteNotification)
+	}
+}
+
+// notificationParams reads the user of the token and the notification of the url, it responds itself on error
+func (h NotificationController) notificationParams(ctx *gin.Context) (int, int, bool) {
+	request := h.NewUserRequest()
+	userID, err := request.GetUserIDFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	notificationID, err := request.GetNotificationID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	return userID, notificationID, true
+}
+
+// GET /users/me/notifications?house_id=1&unread=true, ?archived=true for the archived ones
+func (h NotificationController) getNotifications(ctx *gin.Context) {
+	request := h.NewUserRequest()
+	userID, err := request.GetUserIDFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := request.GetNotificationFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notifications, err := h.userService.GetNotifications(userID, filter)
+	if err != nil {
+		fmt.Println("get notifications failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get notifications failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, notifications)
+}
+
+// GET /users/me/notifications/unread_count?house_id=1, of every house without house_id
+func (h NotificationController) countUnread(ctx *gin.Context) {
+	request := h.NewUserRequest()
+	userID, err := request.GetUserIDFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := request.GetNotificationFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	unread, err := h.userService.CountUnreadNotifications(userID, filter.House_id)
+	if err != nil {
+		fmt.Println("count unread notifications failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "count unread notifications failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"unread": unread})
+}
+
+// POST /users/me/notifications/read_all?house_id=1, of every house without house_id
+func (h NotificationController) markAllRead(ctx *gin.Context) {
+	request := h.NewUserRequest()
+	userID, err := request.GetUserIDFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := request.GetNotificationFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	marked, err := h.userService.MarkAllNotificationsRead(userID, filter.House_id)
+	if err != nil {
+		fmt.Println("mark all notifications read failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "mark all notifications read failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Notifications marked read", "marked": marked})
+}
+
+func (h NotificationController) markRead(ctx *gin.Context) {
+	h.setRead(ctx, true)
+}
+
+// DELETE /users/me/notifications/5/read marks the notification unread again
+func (h NotificationController) markUnread(ctx *gin.Context) {
+	h.setRead(ctx, false)
+}
+
+func (h NotificationController) setRead(ctx *gin.Context, read bool) {
+	userID, notificationID, ok := h.notificationParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.MarkNotificationRead(userID, notificationID, read); err != nil {
+		fmt.Println("mark notification read failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "mark notification read failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Notification updated successfully"})
+}
+
+func (h NotificationController) archive(ctx *gin.Context) {
+	userID, notificationID, ok := h.notificationParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.ArchiveNotification(userID, notificationID); err != nil {
+		fmt.Println("archive notification failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "archive notification failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Notification archived successfully"})
+}
+
+func (h NotificationController) deleteNotification(ctx *gin.Context) {
+	userID, notificationID, ok := h.notificationParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.DeleteNotification(userID, notificationID); err != nil {
+		fmt.Println("delete notification failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "delete notification failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Notification deleted successfully"})
+}
@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/entity"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoomController struct {
+	roomService     usecase.RoomUsecase
+	NewHouseRequest func() request.HouseRequest
+}
+
+func SetupRoomRoutes(router *gin.Engine, houseMember gin.HandlerFunc, roomService usecase.RoomUsecase) {
+	roomController := RoomController{
+		roomService:     roomService,
+		NewHouseRequest: request.NewHouseRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/rooms", roomController.getRooms)
+		houseRoutes.POST("/:id/rooms", roomController.createRoom)
+		houseRoutes.GET("/:id/rooms/:room_id", roomController.getRoom)
+		houseRoutes.PUT("/:id/rooms/:room_id", roomController.renameRoom)
+		houseRoutes.DELETE("/:id/rooms/:room_id", roomController.deleteRoom)
+		houseRoutes.POST("/:id/rooms/:room_id/commands", roomController.sendRoomCommand)
+		houseRoutes.PUT("/:id/devices/:device_id/room", roomController.setDeviceRoom)
+	}
+}
+
+type roomBody struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// roomParams reads the house and the room of the url, it responds itself on error
+func (h RoomController) roomParams(ctx *gin.Context) (int, int, bool) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	roomID, err := request.GetIntParam(ctx, "room_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	return houseID, roomID, true
+}
+
+// GET /houses/1/rooms, every room with its device counts and the average of its sensors
+func (h RoomController) getRooms(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rooms, err := h.roomService.GetRooms(houseID)
+	if err != nil {
+		fmt.Println("get rooms failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get rooms failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rooms)
+}
+
+// POST /houses/1/rooms {"name": "Bedroom"}
+func (h RoomController) createRoom(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body roomBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room := entity.Room{House_id: houseID, Name: body.Name}
+	if err := h.roomService.CreateRoom(&room); err != nil {
+		fmt.Println("create room failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "create room failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, room)
+}
+
+func (h RoomController) getRoom(ctx *gin.Context) {
+	houseID, roomID, ok := h.roomParams(ctx)
+	if !ok {
+		return
+	}
+
+	room, err := h.roomService.GetRoom(houseID, roomID)
+	if err != nil {
+		fmt.Println("get room failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get room failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, room)
+}
+
+// PUT /houses/1/rooms/2 {"name": "Guest room"}
+func (h RoomController) renameRoom(ctx *gin.Context) {
+	houseID, roomID, ok := h.roomParams(ctx)
+	if !ok {
+		return
+	}
+
+	var body roomBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room := entity.Room{ID: roomID, House_id: houseID, Name: body.Name}
+	if err := h.roomService.RenameRoom(&room); err != nil {
+		fmt.Println("rename room failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "rename room failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Room renamed successfully"})
+}
+
+// DELETE /houses/1/rooms/2, the devices of the room are kept without room
+func (h RoomController) deleteRoom(ctx *gin.Context) {
+	houseID, roomID, ok := h.roomParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.roomService.DeleteRoom(houseID, roomID); err != nil {
+		fmt.Println("delete room failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "delete room failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Room deleted successfully"})
+}
+
+// POST /houses/1/rooms/2/commands {"command": "off"}, to every device of the room that takes the command
+func (h RoomController) sendRoomCommand(ctx *gin.Context) {
+	houseID, roomID, ok := h.roomParams(ctx)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Command string  `json:"command" binding:"required"`
+		Value   float64 `json:"value"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, err := h.NewHouseRequest().GetActor(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	results, err := h.roomService.SendRoomCommand(houseID, roomID, body.Command, body.Value, actor)
+	if err != nil {
+		fmt.Println("send room command failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "send room command failed", "error": err.Error()})
+		return
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+
+	// the commands that were not sent yet are retried by the outbox
+	status := http.StatusAccepted
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	ctx.JSON(status, gin.H{"message": "Room command sent", "failed": failed, "results": results})
+}
+
+// PUT /houses/1/devices/3/room {"room_id": 2}, {"room_id": null} takes the device out of its room
+func (h RoomController) setDeviceRoom(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deviceID, err := request.GetIntParam(ctx, "device_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body struct {
+		Room_id *int `json:"room_id"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.roomService.SetDeviceRoom(houseID, deviceID, body.Room_id); err != nil {
+		fmt.Println("set device room failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "set device room failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Device room updated successfully"})
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/entity"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleController struct {
+	scheduleService usecase.ScheduleUsecase
+	NewHouseRequest func() request.HouseRequest
+}
+
+func SetupScheduleRoutes(router *gin.Engine, houseMember gin.HandlerFunc, scheduleService usecase.ScheduleUsecase) {
+	scheduleController := ScheduleController{
+		scheduleService: scheduleService,
+		NewHouseRequest: request.NewHouseRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.PUT("/:id/location", scheduleController.setHouseLocation)
+		houseRoutes.GET("/:id/schedules", scheduleController.getSchedules)
+		houseRoutes.POST("/:id/schedules", scheduleController.createSchedule)
+		houseRoutes.GET("/:id/schedules/:schedule_id", scheduleController.getSchedule)
+		houseRoutes.PUT("/:id/schedules/:schedule_id", scheduleController.updateSchedule)
+		houseRoutes.DELETE("/:id/schedules/:schedule_id", scheduleController.deleteSchedule)
+	}
+}
+
+// PUT /houses/1/location {"latitude": 10.77, "longitude": 106.70}
+func (h ScheduleController) setHouseLocation(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var location struct {
+		Latitude  *float64 `json:"latitude" binding:"required"`
+		Longitude *float64 `json:"longitude" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&location); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.scheduleService.SetHouseLocation(houseID, *location.Latitude, *location.Longitude); err != nil {
+		fmt.Println("set house location failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "set house location failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "House location updated successfully"})
+}
+
+func (h ScheduleController) getSchedules(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedules, err := h.scheduleService.GetSchedulesByHouseID(houseID)
+	if err != nil {
+		fmt.Println("get schedules failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get schedules failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedules)
+}
+
+func (h ScheduleController) getSchedule(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	scheduleID, err := request.GetIntParam(ctx, "schedule_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.scheduleService.GetScheduleByID(houseID, scheduleID)
+	if err != nil {
+		fmt.Println("get schedule failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get schedule failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedule)
+}
+
+// POST /houses/1/schedules
+//
+//	{"name": "Lights at dusk", "kind": "sun", "sun_event": "sunset", "offset_minutes": -15,
+//	 "action": "device", "device_type": "Light", "command": "on", "enabled": true, "catch_up": true}
+func (h ScheduleController) createSchedule(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var schedule entity.Schedule
+	if err := ctx.ShouldBindJSON(&schedule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schedule.ID = 0
+	schedule.House_id = houseID
+	schedule.Last_run = nil
+	schedule.Last_status = ""
+
+	if err := h.scheduleService.CreateSchedule(&schedule); err != nil {
+		fmt.Println("create schedule failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "create schedule failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, schedule)
+}
+
+func (h ScheduleController) updateSchedule(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	scheduleID, err := request.GetIntParam(ctx, "schedule_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var schedule entity.Schedule
+	if err := ctx.ShouldBindJSON(&schedule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schedule.ID = scheduleID
+	schedule.House_id = houseID
+
+	if err := h.scheduleService.UpdateSchedule(&schedule); err != nil {
+		fmt.Println("update schedule failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "update schedule failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedule)
+}
+
+func (h ScheduleController) deleteSchedule(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	scheduleID, err := request.GetIntParam(ctx, "schedule_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.scheduleService.DeleteSchedule(houseID, scheduleID); err != nil {
+		fmt.Println("delete schedule failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "delete schedule failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Schedule deleted successfully"})
+}
@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/entity"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SettingController struct {
+	userService     usecase.UserUsecase
+	NewHouseRequest func() request.HouseRequest
+}
+
+func SetupSettingRoutes(router *gin.Engine, houseMember gin.HandlerFunc, userService usecase.UserUsecase) {
+	settingController := SettingController{
+		userService:     userService,
+		NewHouseRequest: request.NewHouseRequest,
+	}
+
+	houseRoutes := router.Group("/houses").Use(middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/settings", settingController.getSettings)
+		houseRoutes.POST("/:id/settings", settingController.createSetting)
+		houseRoutes.POST("/:id/settings/snapshot", settingController.snapshotSetting)
+		houseRoutes.GET("/:id/settings/:name", settingController.getSetting)
+		houseRoutes.PUT("/:id/settings/:name", settingController.renameSetting)
+		houseRoutes.PUT("/:id/settings/:name/sets", settingController.replaceSets)
+		houseRoutes.DELETE("/:id/settings/:name", settingController.deleteSetting)
+		houseRoutes.POST("/:id/settings/:name/clone", settingController.cloneSetting)
+		houseRoutes.POST("/:id/settings/:name/activate", settingController.activateSetting)
+	}
+}
+
+// POST /houses/1/settings/Night/activate
+func (h SettingController) activateSetting(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	settingName := ctx.Param("name")
+
+	actor, err := request.GetActor(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	results, err := h.userService.ActivateHouseSetting(houseID, settingName, actor)
+	if err != nil {
+		fmt.Println("activate house setting failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "activate house setting failed", "error": err.Error()})
+		return
+	}
+
+	failed, skipped := 0, 0
+	for _, result := range results {
+		if result.Skipped {
+			skipped++
+		} else if !result.Success {
+			failed++
+		}
+	}
+
+	// some devices did not follow the setting, the client reads which ones in the results
+	status := http.StatusOK
+	if failed > 0 || skipped > 0 {
+		status = http.StatusMultiStatus
+	}
+	ctx.JSON(status, gin.H{"message": "House setting activated", "failed": failed, "skipped": skipped, "results": results})
+}
+
+type settingBody struct {
+	Name string       `json:"name" binding:"required"`
+	Sets []entity.Set `json:"sets"`
+}
+
+func (h SettingController) getSettings(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.userService.GetHouseSettingByHouseID(houseID)
+	if err != nil {
+		fmt.Println("get house settings failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get house settings failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (h SettingController) getSetting(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sets, err := h.userService.GetHouseSetting(houseID, ctx.Param("name"))
+	if err != nil {
+		fmt.Println("get house setting failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get house setting failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"name": ctx.Param("name"), "sets": sets})
+}
+
+// POST /houses/1/settings {"name": "Night", "sets": [{"device_id": 2, "device_data": 1, "device_state": true}]}
+func (h SettingController) createSetting(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body settingBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.CreateHouseSetting(houseID, body.Name, body.Sets); err != nil {
+		fmt.Println("create house setting failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "create house setting failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"name": body.Name, "sets": body.Sets})
+}
+
+// POST /houses/1/settings/snapshot {"name": "Evening"}
+func (h SettingController) snapshotSetting(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body settingBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sets, err := h.userService.SnapshotHouseSetting(houseID, body.Name)
+	if err != nil {
+		fmt.Println("snapshot house setting failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "snapshot house setting failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"name": body.Name, "sets": sets})
+}
+
+// POST /houses/1/settings/Night/clone {"name": "Night 2"}
+func (h SettingController) cloneSetting(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body settingBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sets, err := h.userService.CloneHouseSetting(houseID, ctx.Param("name"), body.Name)
+	if err != nil {
+		fmt.Println("clone house setting failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "clone house setting failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"name": body.Name, "sets": sets})
+}
+
+// PUT /houses/1/settings/Night {"name": "Sleep"}
+func (h SettingController) renameSetting(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body settingBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.RenameHouseSetting(houseID, ctx.Param("name"), body.Name); err != nil {
+		fmt.Println("rename house setting failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "rename house setting failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "House setting renamed successfully"})
+}
+
+// PUT /houses/1/settings/Night/sets [{"device_id": 2, "device_data": 1, "device_state": true}]
+func (h SettingController) replaceSets(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var sets []entity.Set
+	if err := ctx.ShouldBindJSON(&sets); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.ReplaceSets(houseID, ctx.Param("name"), sets); err != nil {
+		fmt.Println("replace sets failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "replace sets failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"name": ctx.Param("name"), "sets": sets})
+}
+
+// DELETE /houses/1/settings/Night, 409 while a scene schedule runs the setting
+func (h SettingController) deleteSetting(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.DeleteHouseSetting(houseID, ctx.Param("name")); err != nil {
+		fmt.Println("delete house setting failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "delete house setting failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "House setting deleted successfully"})
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"go-jwt/internal/middleware"
+	request "go-jwt/internal/request"
+	usecase "go-jwt/internal/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SnapshotController struct {
+	deviceService   usecase.DeviceUsecase
+	NewHouseRequest func() request.HouseRequest
+}
+
+func SetupSnapshotRoutes(router *gin.Engine, houseMember gin.HandlerFunc, deviceService usecase.DeviceUsecase) {
+	snapshotController := SnapshotController{
+		deviceService:   deviceService,
+		NewHouseRequest: request.NewHouseRequest,
+	}
+
+	// the images of the visitors are only sent for a token in the Authorization header
+	houseRoutes := router.Group("/houses").Use(middleware.HeaderTokenMiddleware(), middleware.JwtAuthMiddleware(), houseMember)
+	{
+		houseRoutes.Use(middleware.CORS())
+		houseRoutes.GET("/:id/snapshots", snapshotController.getSnapshots)
+		houseRoutes.GET("/:id/snapshots/:snapshot_id/image", snapshotController.getSnapshotImage)
+	}
+}
+
+// GET /houses/1/snapshots?limit=20, the latest visitors first
+func (h SnapshotController) getSnapshots(ctx *gin.Context) {
+	houseID, err := h.NewHouseRequest().GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'limit' value. Must be between 1 and 500"})
+		return
+	}
+
+	snapshots, err := h.deviceService.GetSnapshots(houseID, limit)
+	if err != nil {
+		fmt.Println("get snapshots failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get snapshots failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, snapshots)
+}
+
+// GET /houses/1/snapshots/2/image with the token in the Authorization header, the app shows it from a blob url
+func (h SnapshotController) getSnapshotImage(ctx *gin.Context) {
+	request := h.NewHouseRequest()
+	houseID, err := request.GetHouseID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	snapshotID, err := request.GetIntParam(ctx, "snapshot_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot, image, err := h.deviceService.GetSnapshotImage(houseID, snapshotID)
+	if err != nil {
+		fmt.Println("get snapshot image failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get snapshot image failed", "error": err.Error()})
+		return
+	}
+
+	ctx.Header("Cache-Control", "private, max-age=86400")
+	ctx.Data(http.StatusOK, snapshot.Content_type, image)
+}
@@ -1,28 +1,30 @@
 package controller
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"go-jwt/internal/entity"
 	"go-jwt/internal/middleware"
 	request "go-jwt/internal/request"
 	usecase "go-jwt/internal/usecase"
-	"io"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type UserController struct {
 	userService    usecase.UserUsecase
+	lockoutService usecase.LockoutUsecase
 	NewUserRequest func() request.UserRequest
 }
 
-func SetupUserRoutes(router *gin.Engine, userService usecase.UserUsecase) {
+func SetupUserRoutes(router *gin.Engine, userService usecase.UserUsecase, lockoutService usecase.LockoutUsecase) {
 	userController := UserController{
 		userService:    userService,
+		lockoutService: lockoutService,
 		NewUserRequest: request.NewUserRequest,
 	}
 
@@ -37,6 +39,7 @@ func SetupUserRoutes(router *gin.Engine, userService usecase.UserUsecase) {
 	{
 		userRoutes.Use(middleware.CORS())
 		userRoutes.GET("/:id", userController.get)
+		userRoutes.GET("/me/dashboard", userController.getMyDashboard)
 		// devices
 		userRoutes.POST("/turnOnLight", userController.turnOnLight)
 		userRoutes.POST("/turnOffLight", userController.turnOffLight)
@@ -67,15 +70,25 @@ func (h UserController) login(ctx *gin.Context) {
 		return
 	}
 
+	if err := h.lockoutService.CheckLogin(request.GetUsername(), ctx.ClientIP()); err != nil {
+		setRetryAfter(ctx, err)
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"message": "login failed", "error": err.Error()})
+		return
+	}
+
 	user, token, house_ids, err := h.userService.AuthenticateUser(request.GetUsername(), request.GetPassword())
 
 	if err != nil {
 		fmt.Println("login user failed:", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, entity.ErrUserNotFound) || errors.Is(err, entity.ErrUserPasswordNotMatch) {
+			h.lockoutService.LoginFailed(request.GetUsername(), ctx.ClientIP())
+		}
 		// 404 not found http status code
 		ctx.JSON(http.StatusNotFound, gin.H{"message": "login failed", "error": err.Error()})
 		return
 	}
 
+	h.lockoutService.LoginSucceeded(request.GetUsername(), ctx.ClientIP())
 	ctx.JSON(http.StatusOK, gin.H{"token": token, "user": user, "house_ids": house_ids})
 }
 
@@ -100,40 +113,50 @@ func (h UserController) get(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, user)
 }
 
-func (h UserController) turnOnLight(ctx *gin.Context) {
+// The command routes answer 200 like before the outbox, which the app expects. The command in the response
+// may still be queued, its status is on /houses/:id/commands/:command_id.
 
-	err := h.userService.TurnOnLight(1)
+// commandTarget is the house of the command, see userHouse, and the user of the token that sends it
+func (h UserController) commandTarget(ctx *gin.Context) (int, entity.Actor, error) {
+	actor, err := h.NewUserRequest().GetActor(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
-		return
+		return 0, actor, err
+	}
+	houseID, err := h.userHouse(ctx)
+	if err != nil {
+		return 0, actor, err
 	}
+	return houseID, actor, nil
+}
 
-	_ = h.userService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Light",
-		Time:          time.Now(),
-		Type_of_event: "Turn on the light",
-	})
+func (h UserController) turnOnLight(ctx *gin.Context) {
+	houseID, actor, err := h.commandTarget(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	command, err := h.userService.TurnOnLight(houseID, actor)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Light turned on successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Light turned on successfully", "command": command})
 }
 
 func (h UserController) turnOffLight(ctx *gin.Context) {
-
-	err := h.userService.TurnOffLight(1)
+	houseID, actor, err := h.commandTarget(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	command, err := h.userService.TurnOffLight(houseID, actor)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	_ = h.userService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Light",
-		Time:          time.Now(),
-		Type_of_event: "Turn off the light",
-	})
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "Light turned off successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Light turned off successfully", "command": command})
 }
 
 func (h UserController) updateLightLevel(ctx *gin.Context) {
@@ -146,20 +169,18 @@ func (h UserController) updateLightLevel(ctx *gin.Context) {
 		return
 	}
 
-	err := h.userService.UpdateLightLevel(1, light_level)
+	houseID, actor, err := h.commandTarget(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	command, err := h.userService.UpdateLightLevel(houseID, light_level, actor)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	_ = h.userService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Light",
-		Time:          time.Now(),
-		Type_of_event: "Update the light level to " + strconv.FormatFloat(light_level, 'f', -1, 64),
-	})
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "Light level updated successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Light level updated successfully", "command": command})
 }
 
 func (h UserController) updateFanSpeed(ctx *gin.Context) {
@@ -171,163 +192,123 @@ func (h UserController) updateFanSpeed(ctx *gin.Context) {
 		return
 	}
 
-	err := h.userService.UpdateFanSpeed(1, fan_speed)
+	houseID, actor, err := h.commandTarget(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	command, err := h.userService.UpdateFanSpeed(houseID, fan_speed, actor)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	_ = h.userService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Fan",
-		Time:          time.Now(),
-		Type_of_event: "Update the fan speed to " + strconv.FormatFloat(fan_speed, 'f', -1, 64),
-	})
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "Fan speed updated successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Fan speed updated successfully", "command": command})
 }
 
 func (h UserController) turnOnFan(ctx *gin.Context) {
-
-	err := h.userService.TurnOnFan(1)
+	houseID, actor, err := h.commandTarget(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	command, err := h.userService.TurnOnFan(houseID, actor)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	_ = h.userService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Fan",
-		Time:          time.Now(),
-		Type_of_event: "Turn on the fan",
-	})
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "Fan turned on successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Fan turned on successfully", "command": command})
 }
 
 func (h UserController) turnOffFan(ctx *gin.Context) {
-
-	err := h.userService.TurnOffFan(1)
+	houseID, actor, err := h.commandTarget(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	command, err := h.userService.TurnOffFan(houseID, actor)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	_ = h.userService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Fan",
-		Time:          time.Now(),
-		Type_of_event: "Turn off the fan",
-	})
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Fan turned off successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Fan turned off successfully", "command": command})
 }
 
 func (h UserController) openDoor(ctx *gin.Context) {
-
-	err := h.userService.OpenDoor(1)
+	houseID, actor, err := h.commandTarget(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	command, err := h.userService.OpenDoor(houseID, actor)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	_ = h.userService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Door",
-		Time:          time.Now(),
-		Type_of_event: "Open the door",
-	})
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "Door opened successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Door opened successfully", "command": command})
 }
 
 func (h UserController) closeDoor(ctx *gin.Context) {
-
-	err := h.userService.CloseDoor(1)
+	houseID, actor, err := h.commandTarget(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	command, err := h.userService.CloseDoor(houseID, actor)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	_ = h.userService.CreateActivityLog(&entity.ActivityLog{
-		House_id:      1,
-		Device:        "Door",
-		Time:          time.Now(),
-		Type_of_event: "Close the door",
-	})
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "Door closed successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Door closed successfully", "command": command})
 }
 
-func (h UserController) getDashboardData(ctx *gin.Context) {
-
-	// temperature, humid, light, fan_speed, err := h.userService.GetDashboardData(1)
-	lightURL := "https://io.adafruit.com/api/v2/QuangThien15/feeds/iot-alarm/data"
-	lightLevelURL := "https://io.adafruit.com/api/v2/QuangThien15/feeds/iot-state/data"
-	fanURL := "https://io.adafruit.com/api/v2/QuangThien15/feeds/iot-fan/data"
-	fanSpeedURL := "https://io.adafruit.com/api/v2/QuangThien15/feeds/iot-fanspeed/data"
-	doorURL := "https://io.adafruit.com/api/v2/QuangThien15/feeds/iot-door/data"
-	temperatureURL := "https://io.adafruit.com/api/v2/QuangThien15/feeds/iot-temperature/data"
-	humidityURL := "https://io.adafruit.com/api/v2/QuangThien15/feeds/iot-humidity/data"
-
-	URLmaps := map[string]string{
-		"light":       lightURL,
-		"light_level": lightLevelURL,
-		"fan":         fanURL,
-		"fan_speed":   fanSpeedURL,
-		"door":        doorURL,
-		"temperature": temperatureURL,
-		"humidity":    humidityURL,
+// userHouse is the house of ?house_id=, which the user of the token must own. Without house_id it is the house
+// of the user when they own only one, the app was written for a single house.
+func (h UserController) userHouse(ctx *gin.Context) (int, error) {
+	request := h.NewUserRequest()
+	userID, err := request.GetUserIDFromToken(ctx)
+	if err != nil {
+		return 0, entity.ErrTokenWithoutUser
 	}
-
-	res := make(map[string]string)
-
-	// Create a new HTTP client
-	client := &http.Client{}
-
-	for key, URL := range URLmaps {
-		// Create a GET request
-		req, err := http.NewRequest(http.MethodGet, URL, nil)
-
+	houseID := request.GetHouseIDFromURL(ctx)
+	if houseID <= 0 {
+		houseIDs, err := h.userService.GetHouseIDs(userID)
 		if err != nil {
-			fmt.Println("Error creating request:", err)
-			return
+			return 0, err
 		}
-
-		// Send the request
-		resp, err := client.Do(req)
-
-		if err != nil {
-			fmt.Println("Error sending request:", err)
-			return
-		}
-
-		defer resp.Body.Close()
-
-		// Read the response body
-		body, err := io.ReadAll(resp.Body)
-
-		if err != nil {
-			fmt.Println("Error reading response body:", err)
-			return
-		}
-		// take the "value" from the JSON response
-
-		var data []map[string]interface{}
-
-		// Unmarshal JSON into the struct know that data is an array of JSON objects
-		if err := json.Unmarshal(body, &data); err != nil {
-			fmt.Println("Error parsing JSON:", err)
-			return
+		if len(houseIDs) != 1 {
+			return 0, entity.ErrHouseIDRequired
 		}
+		return houseIDs[0], nil
+	}
+	member, err := h.userService.IsHouseMember(userID, houseID)
+	if err != nil {
+		return 0, err
+	}
+	if !member {
+		return 0, entity.ErrNotHouseMember
+	}
+	return houseID, nil
+}
 
-		// Extract "value" values from the first JSON object
-		value, ok := data[0]["value"].(string)
-		if !ok {
-			fmt.Println("Error getting value from JSON")
-			return
-		}
+// /users/getDashboardData?house_id=1
+func (h UserController) getDashboardData(ctx *gin.Context) {
+	houseID, err := h.userHouse(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"message": "get dashboard data failed", "error": err.Error()})
+		return
+	}
 
-		res[key] = value
+	// temperature, humid, light, fan_speed, err := h.userService.GetDashboardData(1)
+	res, err := h.userService.GetDashboardFeeds()
+	if err != nil {
+		fmt.Println("get dashboard data failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get dashboard data failed", "error": err.Error()})
+		return
 	}
 
 	temperature, _ := strconv.ParseFloat(res["temperature"], 64)
@@ -338,7 +319,6 @@ func (h UserController) getDashboardData(ctx *gin.Context) {
 			Time:        time.Now(),
 			Title:       "Fire Warning!",
 			Description: "Temperature: " + res["temperature"] + "°C, Humidity: " + res["humidity"] + "%",
-			Read:        false,
 		})
 		if err != nil {
 			fmt.Println("create notification failed:", err.Error())
@@ -370,6 +350,12 @@ func (h UserController) getDashboardData(ctx *gin.Context) {
 	light_level, _ := strconv.ParseFloat(res["light_level"], 64)
 	fan_speed, _ := strconv.ParseFloat(res["fan_speed"], 64)
 
+	// the feeds keep the last value of a dead sensor, the offline devices tell which values are stale
+	offline_devices, err := h.userService.GetOfflineDevices(houseID)
+	if err != nil {
+		fmt.Println("get offline devices failed:", err.Error())
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"temperature": temperature,
 		"humidity":    humidity,
@@ -378,9 +364,30 @@ func (h UserController) getDashboardData(ctx *gin.Context) {
 		"door":        door,
 		"light_level": light_level,
 		"fan_speed":   fan_speed,
+		// null when it could not be read
+		"offline_devices": offline_devices,
 	})
 }
 
+// GET /users/me/dashboard, a summary of every house of the user.
+// A house that could not be read fully is returned with what was read and its errors.
+func (h UserController) getMyDashboard(ctx *gin.Context) {
+	userID, err := h.NewUserRequest().GetUserIDFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	dashboards, err := h.userService.GetUserDashboard(userID)
+	if err != nil {
+		fmt.Println("get user dashboard failed:", err.Error())
+		ctx.JSON(errorStatus(err), gin.H{"message": "get user dashboard failed", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dashboards)
+}
+
 func (h UserController) getHouseSettingByHouseID(ctx *gin.Context) {
 	request := h.NewUserRequest()
 	house_id := request.GetHouseIDFromURL(ctx)
@@ -412,12 +419,21 @@ func (h UserController) getSetOfHouseSetting(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, sets)
 }
 
-// /users/getActivityLog?house_id=1
+// /users/getActivityLog?house_id=1, only for the users of the house like /houses/:id/activity
 func (h UserController) getActivityLogByHouseID(ctx *gin.Context) {
 	request := h.NewUserRequest()
-	house_id := request.GetHouseIDFromURL(ctx)
+	house_id, err := h.userHouse(ctx)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"message": "get activity log failed", "error": err.Error()})
+		return
+	}
+	roomID, err := request.GetRoomIDFromURL(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	activityLog, err := h.userService.GetActivityLogByHouseID(house_id)
+	activityLog, err := h.userService.GetActivityLogByHouseID(house_id, roomID)
 
 	if err != nil {
 		fmt.Println("get activity log failed:", err.Error())
@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ActivityFilter selects entries of the activity log of a house, a zero field does not filter
+type ActivityFilter struct {
+	Device_id *int
+	Room_id   *int       // the devices now in the room
+	Device    string     // the Device column, e.g. "Camera" or "Setting"
+	From      *time.Time // included
+	To        *time.Time // excluded
+	Search    string     // a part of Type_of_event, the case is ignored
+	Actor     string     // the type of the actor, e.g. "user"
+	Actor_id  *int       // only with Actor
+	Source    string
+	Event     string // an event code, e.g. "face.recognized"
+	Cursor    string // Next_cursor of the previous page, empty for the first page
+	Limit     int
+}
+
+// ActivityCursor is the last entry of a page, the next page starts right after it
+type ActivityCursor struct {
+	Time time.Time
+	ID   int
+}
+
+// ActivityPage is a page of the activity log, the newest entries first.
+// Next_cursor is empty on the last page.
+type ActivityPage struct {
+	Items       []ActivityLog `json:"items"`
+	Next_cursor string        `json:"next_cursor,omitempty"`
+}
+
+// Actor types, who or what caused an action
+const (
+	ActorUser     = "user"
+	ActorDevice   = "device"
+	ActorSchedule = "schedule" // an automation rule
+	ActorPerson   = "person"   // a face verified by a camera
+	ActorSystem   = "system"
+)
+
+// Sources, the channel an action came through
+const (
+	SourceApp      = "app" // the /users routes of the app
+	SourceAPI      = "api" // the /houses routes
+	SourceSchedule = "schedule"
+	SourceDevice   = "device"
+	SourceSystem   = "system"
+)
+
+// Event codes of the activity log, Params has the details of each
+const (
+	EventCommandAcknowledged = "command.acknowledged" // command_id, command, value
+	EventSettingActivated    = "setting.activated"    // setting, applied, total
+	EventScheduleRun         = "schedule.run"         // schedule_id, status
+	EventFaceRecognized      = "face.recognized"      // camera_id, person_id, confidence
+	EventFaceRejected        = "face.rejected"        // camera_id, confidence
+)
+
+// Actor is who or what caused an action, stored with the commands and the activity log.
+// ID is the user, the device, the schedule or the person, null for the system.
+type Actor struct {
+	Type   string `gorm:"column:type" json:"type"`
+	ID     *int   `gorm:"column:id" json:"id"`
+	Name   string `gorm:"column:name" json:"name,omitempty"`
+	Source string `gorm:"column:source" json:"source"`
+}
+
+func UserActor(userID int, source string) Actor {
+	return Actor{Type: ActorUser, ID: &userID, Source: source}
+}
+
+func DeviceActor(deviceID int, name string) Actor {
+	return Actor{Type: ActorDevice, ID: &deviceID, Name: name, Source: SourceDevice}
+}
+
+func ScheduleActor(schedule *Schedule) Actor {
+	return Actor{Type: ActorSchedule, ID: &schedule.ID, Name: schedule.Name, Source: SourceSchedule}
+}
+
+func PersonActor(personID int, name string) Actor {
+	return Actor{Type: ActorPerson, ID: &personID, Name: name, Source: SourceDevice}
+}
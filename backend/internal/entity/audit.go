@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAuditSigningKeyMissing = errors.New("AUDIT_SIGNING_KEY is not set")
+	ErrAuditSeqTaken          = errors.New("another audit entry took the seq")
+)
+
+// Event codes of the audit log, on top of the face events of the activity log
+const (
+	EventDoorCommand      = "door.command"       // command_id, command, the command was issued
+	EventDoorAcknowledged = "door.acknowledged"  // command_id, command, the door followed it
+	EventDeviceKeyIssued  = "device_key.issued"  // key_prefix
+	EventDeviceKeyRevoked = "device_key.revoked" // keys
+)
+
+// AuditGenesisHash is the previous hash of the first entry of a house
+const AuditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// AuditEntry is an append-only record of a security event of a house. Seq counts the entries of the house
+// from 1, Hash covers the entry and Prev_hash, so editing or deleting an entry breaks the chain after it.
+type AuditEntry struct {
+	ID          int                    `gorm:"primaryKey;column:Entry_id" json:"entry_id"`
+	House_id    int                    `gorm:"column:House_id;uniqueIndex:idx_audit_entry_house_seq,priority:1" json:"house_id"`
+	Seq         int64                  `gorm:"column:Seq;uniqueIndex:idx_audit_entry_house_seq,priority:2" json:"seq"`
+	Time        time.Time              `gorm:"column:Time" json:"time"`
+	Event_code  string                 `gorm:"column:Event_code" json:"event_code"`
+	Actor       Actor                  `gorm:"embedded;embeddedPrefix:Actor_" json:"actor"`
+	Device_id   *int                   `gorm:"column:Device_id" json:"device_id"`
+	Description string                 `gorm:"column:Description" json:"description"`
+	Params      map[string]interface{} `gorm:"column:Params;serializer:json" json:"params"`
+	Prev_hash   string                 `gorm:"column:Prev_hash;size:64" json:"prev_hash"`
+	Hash        string                 `gorm:"column:Hash;size:64" json:"hash"`
+}
+
+// AuditCheckpoint is the hash of the last entry of a house at a time, signed with AUDIT_SIGNING_KEY.
+// A chain rebuilt from scratch after an edit no longer matches the signed hashes.
+type AuditCheckpoint struct {
+	ID         int       `gorm:"primaryKey;column:Checkpoint_id" json:"checkpoint_id"`
+	House_id   int       `gorm:"column:House_id;index" json:"house_id"`
+	Seq        int64     `gorm:"column:Seq" json:"seq"`
+	Hash       string    `gorm:"column:Hash;size:64" json:"hash"`
+	Signature  string    `gorm:"column:Signature;size:64" json:"signature"` // hex HMAC-SHA256
+	Created_at time.Time `gorm:"column:Created_at" json:"created_at"`
+}
+
+// AuditVerification is the result of checking the chain of a house. Broken_seq is the first entry that does
+// not match, Signed is false when the signatures of the checkpoints could not be checked.
+type AuditVerification struct {
+	House_id    int       `json:"house_id"`
+	Valid       bool      `json:"valid"`
+	Entries     int64     `json:"entries"`
+	Last_hash   string    `json:"last_hash"`
+	Checkpoints int       `json:"checkpoints"`
+	Signed      bool      `json:"signed"`
+	Broken_seq  *int64    `json:"broken_seq,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Checked_at  time.Time `json:"checked_at"`
+}
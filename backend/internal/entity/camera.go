@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidDeviceKey   = errors.New("invalid device key")
+	ErrCredentialNotFound = errors.New("the device has no key")
+	ErrNotACamera         = errors.New("the device is not a camera")
+	ErrInvalidCamera      = errors.New("invalid camera")
+)
+
+// Camera is an Iot_device of type "Camera" that sends the faces at the door
+type Camera struct {
+	Device_id int    `gorm:"primaryKey;column:Device_id;autoIncrement:false" json:"device_id"`
+	House_id  int    `gorm:"foreignKey:House_id" json:"house_id"`
+	Door_id   *int   `gorm:"Door_id" json:"door_id"`                 // the door it opens, nil for the first door of the house
+	Name      string `gorm:"->;-:migration;column:Name" json:"name"` // from Iot_device
+}
+
+// DeviceCredential is the key a device sends with its requests. Only the sha256 of the key is kept,
+// the key itself is shown once when it is issued.
+type DeviceCredential struct {
+	ID           int        `gorm:"primaryKey;column:Credential_id" json:"credential_id"`
+	Device_id    int        `gorm:"Device_id" json:"device_id"`
+	House_id     int        `gorm:"foreignKey:House_id" json:"house_id"`
+	Key_hash     string     `gorm:"Key_hash" json:"-"`
+	Key_prefix   string     `gorm:"Key_prefix" json:"key_prefix"` // the start of the key, to tell the keys apart
+	Created_at   time.Time  `gorm:"Created_at" json:"created_at"`
+	Last_used_at *time.Time `gorm:"Last_used_at" json:"last_used_at"`
+}
@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrCommandNotFound = errors.New("command not found")
+)
+
+// Status of a device command in the outbox
+const (
+	CommandQueued       = "queued"       // waiting to be sent, or to be sent again after a failure
+	CommandSent         = "sent"         // sent to the feed, waiting for the device to echo it
+	CommandAcknowledged = "acknowledged" // the device echoed the value on the feed
+	CommandFailed       = "failed"       // out of attempts, or superseded by a newer command on the same feed
+)
+
+type DeviceCommand struct {
+	ID              int        `gorm:"primaryKey;column:Command_id" json:"command_id"`
+	House_id        int        `gorm:"foreignKey:House_id" json:"house_id"`
+	Device_id       int        `gorm:"Device_id" json:"device_id"`
+	Device_type     string     `gorm:"Device_type" json:"device_type"`
+	Command         string     `gorm:"Command" json:"command"`
+	Value           float64    `gorm:"Value" json:"value"`
+	Feed            string     `gorm:"Feed" json:"feed"`
+	Payload         string     `gorm:"Payload" json:"payload"`
+	Description     string     `gorm:"Description" json:"description"` // written to the activity log on acknowledgement
+	Status          string     `gorm:"Status" json:"status"`
+	Attempts        int        `gorm:"Attempts" json:"attempts"`
+	Last_error      string     `gorm:"Last_error" json:"last_error,omitempty"`
+	Created_at      time.Time  `gorm:"Created_at" json:"created_at"`
+	Next_attempt_at time.Time  `gorm:"Next_attempt_at" json:"-"`
+	Sent_at         *time.Time `gorm:"Sent_at" json:"sent_at"`
+	Acked_at        *time.Time `gorm:"Acked_at" json:"acked_at"`
+	Actor           Actor      `gorm:"embedded;embeddedPrefix:Actor_" json:"actor"` // who sent the command, in the log of its acknowledgement
+}
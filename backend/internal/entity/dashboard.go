@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrDashboardLayoutNotFound = errors.New("dashboard layout not found")
+	ErrInvalidDashboardLayout  = errors.New("invalid dashboard layout")
+)
+
+const (
+	WidgetValue  = "value"  // the current data of the device with its unit
+	WidgetToggle = "toggle" // on and off, only for a device that takes both commands
+)
+
+// DashboardLayout is how a user arranges the dashboard of a house, each user of the house has their own
+type DashboardLayout struct {
+	User_id    int              `gorm:"primaryKey;column:User_id;autoIncrement:false" json:"user_id"`
+	House_id   int              `gorm:"primaryKey;column:House_id;autoIncrement:false" json:"house_id"`
+	Groups     []DashboardGroup `gorm:"serializer:json" json:"groups"`
+	Updated_at *time.Time       `gorm:"Updated_at" json:"updated_at"` // null for the default layout
+}
+
+// DashboardGroup is a section of the dashboard, e.g. a room, its widgets are shown in order
+type DashboardGroup struct {
+	Name    string            `json:"name"`
+	Widgets []DashboardWidget `json:"widgets"`
+}
+
+type DashboardWidget struct {
+	Device_id int    `json:"device_id"`
+	Widget    string `json:"widget"`          // WidgetValue when empty
+	Label     string `json:"label,omitempty"` // the name of the device when empty
+}
+
+// Dashboard is the data of the devices of a house in the layout of the user
+type Dashboard struct {
+	House_id int                  `json:"house_id"`
+	Groups   []DashboardGroupData `json:"groups"`
+}
+
+type DashboardGroupData struct {
+	Name    string       `json:"name"`
+	Widgets []WidgetData `json:"widgets"`
+}
+
+// WidgetData is a widget of the layout with the current data of its device.
+// State is the name of the state of an enum device, e.g. "open" for a door.
+type WidgetData struct {
+	DashboardWidget
+	Device_type string     `json:"device_type"`
+	Data        float64    `json:"data"`
+	State       string     `json:"state,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Online      bool       `json:"online"`
+	Last_seen   *time.Time `json:"last_seen"`
+}
@@ -1,13 +1,38 @@
 package entity
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidCommand = errors.New("invalid device command")
+	ErrCameraNotFound = errors.New("camera not found")
+	// a device can only write its own kind of data, e.g. a fan can not send a temperature
+	ErrWrongDeviceType   = errors.New("the device does not send this kind of data")
+	ErrInvalidTelemetry  = errors.New("invalid telemetry")
+	ErrTelemetryTooLarge = errors.New("telemetry is too large")
+)
 
 type Device struct {
 	ID       int     `gorm:"primaryKey;column:Device_id" json:"device_id"`
-	Type     string  `gorm:"Device_type" json:"device_type"`
-	Name     string  `gorm:"Name" json:"name"`
-	Data     float64 `gorm:"Device_data" json:"device_data"`
+	Type     string  `gorm:"column:Device_type" json:"device_type"`
+	Name     string  `gorm:"column:Name" json:"name"`
+	Data     float64 `gorm:"column:Current_data" json:"device_data"`
 	House_id int     `gorm:"foreignKey:House_id" json:"house_id"`
+	Room_id  *int    `gorm:"column:Room_id" json:"room_id"`
+
+	Last_seen *time.Time `gorm:"column:Last_seen" json:"last_seen"` // the last request of the device
+	Online    bool       `gorm:"column:Online" json:"online"`       // false until the device is first seen
+
+	Schema *DeviceType `gorm:"-" json:"schema,omitempty"` // from Device_type, in the responses
+}
+
+// Reading is one measure in the telemetry of a device, Time is when the device took it
+type Reading struct {
+	Data  float64    `json:"data"`
+	State bool       `json:"state"`
+	Time  *time.Time `json:"time"` // the time of the request when it is missing
 }
 
 type DataRecord struct {
@@ -15,4 +40,21 @@ type DataRecord struct {
 	Time         time.Time `gorm:"primaryKey;column:Date_and_time" json:"time"`
 	Device_data  float64   `gorm:"Device_data" json:"device_data"`
 	Device_state bool      `gorm:"Device_state" json:"device_state"`
+
+	// a suspicious reading is kept but left out of the current data and the statistics
+	Anomaly        bool   `gorm:"column:Anomaly;not null;default:false" json:"anomaly"`
+	Anomaly_reason string `gorm:"Anomaly_reason" json:"anomaly_reason,omitempty"`
+}
+
+// DataStats are computed from the data records of a device between From and To, without the anomalies.
+// Min, Max and Avg are null when there is no record.
+type DataStats struct {
+	Device_id int       `json:"device_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Count     int64     `json:"count"`
+	Anomalies int64     `json:"anomalies"`
+	Min       *float64  `json:"min"`
+	Max       *float64  `json:"max"`
+	Avg       *float64  `json:"avg"`
 }
@@ -0,0 +1,41 @@
+package entity
+
+import "errors"
+
+var (
+	ErrUnknownDeviceType = errors.New("unknown device type")
+	ErrInvalidDeviceData = errors.New("invalid device data")
+)
+
+const (
+	ValueNumber  = "number"  // any value between Min_value and Max_value
+	ValueInteger = "integer" // a whole value between Min_value and Max_value
+	ValueEnum    = "enum"    // the value is the index of one of States
+	ValueNone    = "none"    // the device sends no value, e.g. a camera
+)
+
+// CommandRoute is where a command of a device type is sent: the Adafruit feed, the key of the webhook that
+// writes to it, and the value written. "{value}" in Payload is replaced by the value of the command.
+type CommandRoute struct {
+	Feed    string `json:"feed"`
+	Webhook string `json:"-"`
+	Payload string `json:"payload"`
+}
+
+// DeviceType describes the data a type of device sends and the commands it takes.
+// Value_command is the command that sets the value, e.g. "speed" for a fan, its value follows the same rules as the data.
+// State_commands are the commands that turn the device off then on, e.g. ["close", "open"], none for a sensor.
+type DeviceType struct {
+	Name           string                  `gorm:"primaryKey;column:Name" json:"name"`
+	Value_kind     string                  `gorm:"Value_kind" json:"value_kind"`
+	Unit           string                  `gorm:"Unit" json:"unit"`
+	Min_value      *float64                `gorm:"Min_value" json:"min"`
+	Max_value      *float64                `gorm:"Max_value" json:"max"`
+	States         []string                `gorm:"serializer:json" json:"states"`
+	Commands       []string                `gorm:"serializer:json" json:"commands"`
+	Value_command  string                  `gorm:"Value_command" json:"value_command"`
+	State_commands []string                `gorm:"serializer:json" json:"state_commands"`
+	Routes         map[string]CommandRoute `gorm:"serializer:json" json:"routes"`
+	// seconds without a request before the device is offline, 0 for never, null for DEVICE_OFFLINE_AFTER
+	Offline_after *int `gorm:"Offline_after" json:"offline_after"`
+}
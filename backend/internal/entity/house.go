@@ -1,24 +1,69 @@
 package entity
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrHouseSettingNotFound = errors.New("house setting not found")
+	ErrHouseSettingExists   = errors.New("house setting already exists")
+	ErrHouseSettingInUse    = errors.New("house setting is used by a schedule")
+	ErrInvalidHouseSetting  = errors.New("invalid house setting")
+	ErrFacePersonNotFound   = errors.New("person not found")
+	ErrFaceEncodingNotFound = errors.New("face encoding not found")
+	ErrInvalidFacePerson    = errors.New("invalid person")
+	ErrNoFaceFound          = errors.New("no face found in the image")
+	ErrHouseIDRequired      = errors.New("'house_id' is required for a user of several houses")
+)
 
 type House struct {
 	ID       int    `gorm:"primaryKey;column:House_id" json:"house_id"`
 	Name     string `gorm:"name" json:"name"`
 	Password string `gorm:"password" json:"password"`
+	// location of the house, used for sunrise and sunset schedules
+	Latitude  *float64 `gorm:"Latitude" json:"latitude"`
+	Longitude *float64 `gorm:"Longitude" json:"longitude"`
 }
 
+// ActivityLog is read newest first, the index follows the order of the pages
 type ActivityLog struct {
-	ID            int       `gorm:"primaryKey;column:Activity_id" json:"activity_id"`
-	House_id      int       `gorm:"foreignKey:House_id" json:"house_id"`
-	Time          time.Time `gorm:"Time" json:"time"`
+	ID            int       `gorm:"primaryKey;column:Activity_id;index:idx_activity_log_house_time,priority:3" json:"activity_id"`
+	House_id      int       `gorm:"foreignKey:House_id;index:idx_activity_log_house_time,priority:1" json:"house_id"`
+	Time          time.Time `gorm:"column:Time;index:idx_activity_log_house_time,priority:2" json:"time"`
 	Device        string    `gorm:"Device" json:"device"`
 	Type_of_event string    `gorm:"Type_of_event" json:"type_of_event"`
+	Device_id     *int      `gorm:"column:Device_id;index:idx_activity_log_device" json:"device_id"` // null when the event is not about one device
+
+	Actor      Actor                  `gorm:"embedded;embeddedPrefix:Actor_" json:"actor"`
+	Event_code string                 `gorm:"column:Event_code" json:"event_code"` // one of the Event constants, empty for the older entries
+	Params     map[string]interface{} `gorm:"column:Params;serializer:json" json:"params"`
 }
 
 type FaceEncoding struct {
-	Face_encoding string `gorm:"primaryKey;column:Face_encoding" json:"face_encoding"`
-	House_id      int    `gorm:"primaryKey;foreignKey:House_id" json:"house_id"`
+	Face_encoding string     `gorm:"primaryKey;column:Face_encoding" json:"face_encoding,omitempty"`
+	House_id      int        `gorm:"primaryKey;foreignKey:House_id" json:"house_id"`
+	Encoding_id   int        `gorm:"column:Encoding_id;autoIncrement" json:"encoding_id"`
+	Person_id     *int       `gorm:"Person_id" json:"person_id"` // nil for the encodings enrolled before people existed
+	Created_at    *time.Time `gorm:"Created_at" json:"created_at"`
+}
+
+// FacePerson is someone whose face opens the door of the house, with one encoding per enrolled image
+type FacePerson struct {
+	ID         int            `gorm:"primaryKey;column:Person_id" json:"person_id"`
+	House_id   int            `gorm:"foreignKey:House_id" json:"house_id"`
+	Name       string         `gorm:"Name" json:"name"`
+	User_id    *int           `gorm:"User_id" json:"user_id"` // the user account of the person, if any
+	Created_at time.Time      `gorm:"Created_at" json:"created_at"`
+	Encodings  []FaceEncoding `gorm:"-" json:"encodings"`
+}
+
+// FaceMatch is the result of comparing a face seen by a camera with the faces enrolled in its house
+type FaceMatch struct {
+	Matched     bool    `json:"is_match"`
+	Person_id   *int    `json:"person_id"`
+	Person_name string  `json:"person_name"`
+	Confidence  float64 `json:"confidence"`
 }
 
 //Set and house setting???
@@ -38,3 +83,40 @@ type Set struct {
 	Device_data  float64 `gorm:"Device_data" json:"device_data"`
 	Device_state bool    `gorm:"Device_state" json:"device_state"`
 }
+
+// SetResult is the outcome of pushing one Set of a house setting to its device, or a command to a device of a room
+type SetResult struct {
+	Device_id   int    `json:"device_id"`
+	Device_name string `json:"device_name"`
+	Device_type string `json:"device_type"`
+	Command_ids []int  `json:"command_ids"`
+	Success     bool   `json:"success"`
+	Skipped     bool   `json:"skipped,omitempty"` // nothing was sent, e.g. the device is a sensor or left the house
+	Error       string `json:"error,omitempty"`
+}
+
+// HouseDashboard is the summary of a house on the dashboard of a user. A part that could not be read
+// is left empty and its error is in Errors, the rest of the summary is still there.
+type HouseDashboard struct {
+	House_id             int                 `json:"house_id"`
+	Name                 string              `json:"name"`
+	Sensors              map[string]*float64 `json:"sensors"` // the current data of the first sensor of each type
+	Devices              int                 `json:"devices"`
+	Devices_on           []string            `json:"devices_on"` // the names of the devices that are on
+	Offline_devices      int                 `json:"offline_devices"`
+	Alerts               []Alert             `json:"alerts"`
+	Unread_notifications int64               `json:"unread_notifications"`
+	Errors               []string            `json:"errors,omitempty"`
+}
+
+const (
+	AlertFire    = "fire"
+	AlertOffline = "offline"
+)
+
+// Alert is something on the dashboard that needs the attention of the owners now
+type Alert struct {
+	Type      string `json:"type"`
+	Message   string `json:"message"`
+	Device_id *int   `json:"device_id,omitempty"`
+}
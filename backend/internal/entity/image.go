@@ -0,0 +1,11 @@
+package entity
+
+import "errors"
+
+// errors of the images sent to the face endpoints
+var (
+	ErrImageRequired    = errors.New("an image is required in the 'img' field")
+	ErrImageTooLarge    = errors.New("image is too large")
+	ErrUnsupportedImage = errors.New("unsupported image type")
+	ErrInvalidImage     = errors.New("invalid image")
+)
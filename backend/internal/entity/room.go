@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomExists   = errors.New("room already exists")
+	ErrInvalidRoom  = errors.New("invalid room")
+)
+
+// Room groups the devices of a house, a device is in at most one room
+type Room struct {
+	ID         int       `gorm:"primaryKey;column:Room_id" json:"room_id"`
+	House_id   int       `gorm:"column:House_id" json:"house_id"`
+	Name       string    `gorm:"column:Name" json:"name"`
+	Created_at time.Time `gorm:"column:Created_at" json:"created_at"`
+}
+
+// RoomSummary is a room with the state of its devices.
+// Averages has the mean current data of the sensors of each type, e.g. the temperature of the room.
+type RoomSummary struct {
+	Room
+	Devices         int                 `json:"devices"`
+	Devices_on      []string            `json:"devices_on"`
+	Offline_devices int                 `json:"offline_devices"`
+	Averages        map[string]*float64 `json:"averages"`
+	Device_list     []Device            `json:"device_list,omitempty"` // only for a single room
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrScheduleNotFound    = errors.New("schedule not found")
+	ErrInvalidSchedule     = errors.New("invalid schedule")
+	ErrHouseLocationNotSet = errors.New("house location is not set")
+)
+
+// Kind of a schedule
+const (
+	ScheduleKindCron = "cron" // Cron_expr, e.g. "30 6 * * 1-5"
+	ScheduleKindOnce = "once" // Run_at
+	ScheduleKindSun  = "sun"  // Sun_event plus Offset_minutes at the house location
+)
+
+// Action of a schedule
+const (
+	ScheduleActionDevice = "device" // Device_type + Command + Value
+	ScheduleActionScene  = "scene"  // Setting_name of a House_setting
+)
+
+const (
+	SunEventSunrise = "sunrise"
+	SunEventSunset  = "sunset"
+)
+
+type Schedule struct {
+	ID             int        `gorm:"primaryKey;column:Schedule_id" json:"schedule_id"`
+	House_id       int        `gorm:"foreignKey:House_id" json:"house_id"`
+	Name           string     `gorm:"Name" json:"name"`
+	Kind           string     `gorm:"Kind" json:"kind"`
+	Cron_expr      string     `gorm:"Cron_expr" json:"cron_expr"`
+	Run_at         *time.Time `gorm:"Run_at" json:"run_at"`
+	Sun_event      string     `gorm:"Sun_event" json:"sun_event"`
+	Offset_minutes int        `gorm:"Offset_minutes" json:"offset_minutes"`
+	Action         string     `gorm:"Action" json:"action"`
+	Device_type    string     `gorm:"Device_type" json:"device_type"`
+	Command        string     `gorm:"Command" json:"command"`
+	Value          float64    `gorm:"Value" json:"value"`
+	Setting_name   string     `gorm:"Setting_name" json:"setting_name"`
+	Enabled        bool       `gorm:"Enabled" json:"enabled"`
+	Catch_up       bool       `gorm:"Catch_up" json:"catch_up"` // run once after a restart if a run was missed
+	Next_run       *time.Time `gorm:"Next_run" json:"next_run"`
+	Last_run       *time.Time `gorm:"Last_run" json:"last_run"`
+	Last_status    string     `gorm:"Last_status" json:"last_status"`
+}
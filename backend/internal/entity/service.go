@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errors of the external services (Adafruit, face recognition)
+var (
+	ErrCircuitOpen        = errors.New("service is unavailable, circuit breaker is open")
+	ErrServiceTimeout     = errors.New("service did not answer in time")
+	ErrServiceUnreachable = errors.New("service is unreachable")
+)
+
+// ServiceStatusError is returned when a service answers with a status code that is not 2xx
+type ServiceStatusError struct {
+	Service    string
+	StatusCode int
+	Message    string
+}
+
+func (e *ServiceStatusError) Error() string {
+	return fmt.Sprintf("%s answered %d: %s", e.Service, e.StatusCode, e.Message)
+}
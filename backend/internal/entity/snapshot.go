@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+// Snapshot is an image a camera sent to verify a face, matched or not. The image itself is in the blob store.
+type Snapshot struct {
+	ID           int       `gorm:"primaryKey;column:Snapshot_id" json:"snapshot_id"`
+	House_id     int       `gorm:"foreignKey:House_id" json:"house_id"`
+	Camera_id    int       `gorm:"Camera_id" json:"camera_id"`
+	Activity_id  *int      `gorm:"Activity_id" json:"activity_id"` // the activity log entry of the verification
+	Blob_key     string    `gorm:"Blob_key" json:"-"`
+	Content_type string    `gorm:"Content_type" json:"content_type"`
+	Size         int       `gorm:"Size" json:"size"`
+	Matched      bool      `gorm:"Matched" json:"matched"`
+	Person_id    *int      `gorm:"Person_id" json:"person_id"`
+	Confidence   float64   `gorm:"Confidence" json:"confidence"`
+	Created_at   time.Time `gorm:"Created_at" json:"created_at"`
+}
@@ -2,14 +2,36 @@ package entity
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
 var (
 	ErrUserNotFound         = errors.New("user not found")
 	ErrUserPasswordNotMatch = errors.New("password not match")
+	ErrLockoutNotFound      = errors.New("lockout not found")
+	ErrNotificationNotFound = errors.New("notification not found")
+	ErrTokenWithoutUser     = errors.New("the token has no user id, sign in again")
+	ErrNotHouseMember       = errors.New("the user is not a member of the house")
 )
 
+// RateLimitError is returned when an attempt is refused because there were too many of them
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Lockout is the consecutive failures of a key of the rate limits, like "login:user:thien" or "face:camera:3",
+// and the end of its lockout. It is shared by the instances of the server.
+type Lockout struct {
+	Key          string     `gorm:"primaryKey;column:Lock_key;size:255" json:"key"`
+	Failures     int        `gorm:"column:Failures;not null;default:0" json:"failures"`
+	Locked_until *time.Time `gorm:"column:Locked_until;index" json:"locked_until"`
+}
+
 type User struct {
 	ID       int    `gorm:"primaryKey;column:User_id" json:"user_id"`
 	Username string `gorm:"username" json:"username"`
@@ -22,16 +44,32 @@ type Own struct { // More descriptive name
 	HouseID int `gorm:"primary_key;foreignKey:House_id"`
 }
 
+// Notification is shared by its recipients. Read is the read state of the recipient, from the Send table,
+// the Read column of the Notification table is never written anymore.
 type Notification struct {
-	ID          int       `gorm:"primaryKey;column:Notification_id" json:"notification_id"`
-	Description string    `gorm:"Description" json:"description"`
-	Time        time.Time `gorm:"Time" json:"time"` //3/25/2024 5:06:00 PM
-	Title       string    `gorm:"Title" json:"title"`
-	Read        bool      `gorm:"Read" json:"read"`
+	ID          int        `gorm:"primaryKey;column:Notification_id" json:"notification_id"`
+	Description string     `gorm:"Description" json:"description"`
+	Time        time.Time  `gorm:"Time" json:"time"` //3/25/2024 5:06:00 PM
+	Title       string     `gorm:"Title" json:"title"`
+	Read        bool       `gorm:"column:Read;->" json:"read"`
+	House_id    int        `gorm:"column:House_id;->" json:"house_id"`
+	Read_at     *time.Time `gorm:"column:Read_at;->" json:"read_at"`
+	Archived_at *time.Time `gorm:"column:Archived_at;->" json:"archived_at"`
 }
 
+// Send is a notification to one of its recipients, with the read state of the recipient
 type Send struct {
-	Notification_id int `gorm:"primaryKey;foreignKey:Notification_id" json:"notification_id"`
-	User_id         int `gorm:"primaryKey;foreignKey:User_id" json:"user_id"`
-	House_id        int `gorm:"foreignkey:House_id" json:"house_id"`
+	Notification_id int        `gorm:"primaryKey;foreignKey:Notification_id" json:"notification_id"`
+	User_id         int        `gorm:"primaryKey;foreignKey:User_id" json:"user_id"`
+	House_id        int        `gorm:"foreignkey:House_id" json:"house_id"`
+	Read            bool       `gorm:"column:Read;not null;default:false" json:"read"`
+	Read_at         *time.Time `gorm:"column:Read_at" json:"read_at"`
+	Archived_at     *time.Time `gorm:"column:Archived_at" json:"archived_at"` // archived notifications are only listed on demand
+}
+
+// NotificationFilter of the notifications of a user, House_id nil for every house
+type NotificationFilter struct {
+	House_id *int
+	Unread   bool // only the unread ones
+	Archived bool // the archived ones instead of the others
 }
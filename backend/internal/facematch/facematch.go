@@ -0,0 +1,92 @@
+package facematch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	MetricEuclidean = "euclidean"
+	MetricCosine    = "cosine"
+)
+
+var ErrInvalidEncoding = errors.New("invalid face encoding")
+
+// ParseEncoding reads an encoding stored as a JSON array "[0.1, -0.2]" or printed by numpy "[ 0.1 -0.2\n 0.3]"
+func ParseEncoding(encoding string) ([]float64, error) {
+	var vector []float64
+	if json.Unmarshal([]byte(encoding), &vector) == nil && len(vector) > 0 {
+		return vector, nil
+	}
+
+	fields := strings.FieldsFunc(strings.Trim(strings.TrimSpace(encoding), "[]"), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	vector = make([]float64, 0, len(fields))
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidEncoding, field)
+		}
+		vector = append(vector, value)
+	}
+	if len(vector) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidEncoding)
+	}
+	return vector, nil
+}
+
+// CheckMetric fails on a metric Distance does not know
+func CheckMetric(metric string) error {
+	switch metric {
+	case MetricEuclidean, MetricCosine:
+		return nil
+	}
+	return fmt.Errorf("unknown metric %q, use %q or %q", metric, MetricEuclidean, MetricCosine)
+}
+
+// Distance is the euclidean distance, or 1 - the cosine similarity, of two encodings of the same length
+func Distance(metric string, a []float64, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("%w: lengths %d and %d differ", ErrInvalidEncoding, len(a), len(b))
+	}
+
+	switch metric {
+	case MetricEuclidean:
+		var sum float64
+		for i := range a {
+			sum += (a[i] - b[i]) * (a[i] - b[i])
+		}
+		return math.Sqrt(sum), nil
+
+	case MetricCosine:
+		var dot, normA, normB float64
+		for i := range a {
+			dot += a[i] * b[i]
+			normA += a[i] * a[i]
+			normB += b[i] * b[i]
+		}
+		if normA == 0 || normB == 0 {
+			return 1, nil
+		}
+		return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB)), nil
+	}
+	return 0, CheckMetric(metric)
+}
+
+// Closest returns the index of the candidate closest to probe and its distance, -1 when there is no candidate.
+// The candidates that can not be compared with probe are skipped.
+func Closest(metric string, probe []float64, candidates [][]float64) (int, float64) {
+	best, bestDistance := -1, math.Inf(1)
+	for i, candidate := range candidates {
+		distance, err := Distance(metric, probe, candidate)
+		if err == nil && distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best, bestDistance
+}
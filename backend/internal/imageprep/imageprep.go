@@ -0,0 +1,243 @@
+package imageprep
+
+import (
+	"bytes"
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+var (
+	MaxBytes     = int64(config.Int("IMAGE_MAX_BYTES", 5<<20))
+	MaxDimension = config.Int("IMAGE_MAX_DIMENSION", 1024)
+)
+
+// images with more pixels are refused before they are decoded, 4096x4096 already takes 64 MB once decoded
+const maxPixels = 4096 * 4096
+
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+	TypeWebP = "image/webp"
+)
+
+// Sniff returns the type of the image from its first bytes, "" when it is not a JPEG, a PNG or a WebP
+func Sniff(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return TypeJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return TypePNG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return TypeWebP
+	}
+	return ""
+}
+
+// Prepare checks the image and returns it ready for the face service, with its type.
+// declared is the Content-Type the client gave, it has to agree with the content when it is an image type.
+// A JPEG is turned upright following its EXIF orientation, and a JPEG or a PNG larger than MaxDimension is
+// scaled down. WebP can not be decoded with the standard library, it is only checked and passed through.
+func Prepare(data []byte, declared string) ([]byte, string, error) {
+	if int64(len(data)) > MaxBytes {
+		return nil, "", fmt.Errorf("%w: %d bytes, the limit is %d", entity.ErrImageTooLarge, len(data), MaxBytes)
+	}
+	if len(data) == 0 {
+		return nil, "", fmt.Errorf("%w: the file is empty", entity.ErrInvalidImage)
+	}
+
+	kind := Sniff(data)
+	if kind == "" {
+		return nil, "", fmt.Errorf("%w: only JPEG, PNG and WebP are accepted", entity.ErrUnsupportedImage)
+	}
+	if declared == "image/jpg" {
+		declared = TypeJPEG
+	}
+	switch declared {
+	case "", "application/octet-stream", kind:
+	case TypeJPEG, TypePNG, TypeWebP:
+		return nil, "", fmt.Errorf("%w: declared as %s but the content is %s", entity.ErrUnsupportedImage, declared, kind)
+	default:
+		return nil, "", fmt.Errorf("%w: %s", entity.ErrUnsupportedImage, declared)
+	}
+	if kind == TypeWebP {
+		return data, kind, nil
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", entity.ErrInvalidImage, err.Error())
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, "", fmt.Errorf("%w: the image has no pixel", entity.ErrInvalidImage)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d pixels", entity.ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+
+	orientation := 1
+	if kind == TypeJPEG {
+		orientation = exifOrientation(data)
+	}
+	if orientation == 1 && cfg.Width <= MaxDimension && cfg.Height <= MaxDimension {
+		// nothing to change, keep the original quality
+		return data, kind, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", entity.ErrInvalidImage, err.Error())
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	rgba = orient(downscale(rgba, MaxDimension), orientation)
+
+	out := new(bytes.Buffer)
+	if kind == TypePNG {
+		err = png.Encode(out, rgba)
+	} else {
+		err = jpeg.Encode(out, rgba, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), kind, nil
+}
+
+// downscale shrinks the image so its longest side is max, each pixel is the average of the pixels it covers
+func downscale(src *image.RGBA, max int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= max && h <= max {
+		return src
+	}
+	dw, dh := max, h*max/w
+	if h > w {
+		dw, dh = w*max/h, max
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, (y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, (x+1)*w/dw
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// orient turns the image upright following an EXIF orientation from 1 to 8
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // upside down and mirrored
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotate 90° clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90° counterclockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:sy*src.Stride+sx*4+4])
+		}
+	}
+	return dst
+}
+
+// exifOrientation reads the orientation tag of the EXIF segment of a JPEG, 1 (upright) when there is none
+func exifOrientation(data []byte) int {
+	i := 2 // after the start of image marker
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			// the image data starts, the EXIF segment comes before it
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var u16 func([]byte) int
+	var u32 func([]byte) int
+	switch string(tiff[:2]) {
+	case "II":
+		u16 = func(b []byte) int { return int(b[0]) | int(b[1])<<8 }
+		u32 = func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 | int(b[3])<<24 }
+	case "MM":
+		u16 = func(b []byte) int { return int(b[0])<<8 | int(b[1]) }
+		u32 = func(b []byte) int { return int(b[0])<<24 | int(b[1])<<16 | int(b[2])<<8 | int(b[3]) }
+	default:
+		return 1
+	}
+
+	ifd := u32(tiff[4:8])
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := u16(tiff[ifd:])
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if u16(tiff[entry:]) == 0x0112 {
+			if orientation := u16(tiff[entry+8:]); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 1
+		}
+	}
+	return 1
+}
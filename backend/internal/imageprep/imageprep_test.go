@@ -0,0 +1,187 @@
+package imageprep
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	entity "go-jwt/internal/entity"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exifJPEG is a JPEG start of image followed by an EXIF segment with the orientation tag, in the byte order
+// "II" or "MM", then the rest of a JPEG
+func exifJPEG(order string, orientation uint16, rest []byte) []byte {
+	var bo binary.ByteOrder = binary.LittleEndian
+	if order == "MM" {
+		bo = binary.BigEndian
+	}
+	tiff := make([]byte, 8+2+12+4)
+	copy(tiff, order)
+	bo.PutUint16(tiff[2:], 42)
+	bo.PutUint32(tiff[4:], 8)
+	bo.PutUint16(tiff[8:], 1)
+	bo.PutUint16(tiff[10:], 0x0112)
+	bo.PutUint16(tiff[12:], 3) // SHORT
+	bo.PutUint32(tiff[14:], 1)
+	bo.PutUint16(tiff[18:], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(data[4:], uint16(2+len(segment)))
+	data = append(data, segment...)
+	return append(data, rest...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"intel", exifJPEG("II", 6, []byte{0xFF, 0xDA, 0, 2}), 6},
+		{"motorola", exifJPEG("MM", 8, []byte{0xFF, 0xDA, 0, 2}), 8},
+		{"out of range", exifJPEG("II", 9, nil), 1},
+		{"no exif", []byte{0xFF, 0xD8, 0xFF, 0xDA, 0, 2}, 1},
+		{"other segment first", append([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 4, 'J', 'F'}, exifJPEG("II", 3, nil)[2:]...), 3},
+		{"truncated", exifJPEG("II", 6, nil)[:20], 1},
+		{"bad byte order", exifJPEG("XX", 6, nil), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.data); got != tt.want {
+				t.Errorf("exifOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// labeled is a w x h image whose pixel at x, y has the red value 'a' + y*w + x
+func labeled(w int, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8('a' + y*w + x), A: 255})
+		}
+	}
+	return img
+}
+
+// labels returns the rows of the red values of the image
+func labels(img *image.RGBA) []string {
+	var rows []string
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		row := ""
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			row += string(rune(img.RGBAAt(x, y).R))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestOrient(t *testing.T) {
+	// ab
+	// cd
+	// ef
+	tests := []struct {
+		orientation int
+		want        []string
+	}{
+		{1, []string{"ab", "cd", "ef"}},
+		{2, []string{"ba", "dc", "fe"}},
+		{3, []string{"fe", "dc", "ba"}},
+		{4, []string{"ef", "cd", "ab"}},
+		{5, []string{"ace", "bdf"}},
+		{6, []string{"eca", "fdb"}},
+		{7, []string{"fdb", "eca"}},
+		{8, []string{"bdf", "ace"}},
+		{9, []string{"ab", "cd", "ef"}},
+	}
+	for _, tt := range tests {
+		got := labels(orient(labeled(2, 3), tt.orientation))
+		if len(got) != len(tt.want) {
+			t.Errorf("orientation %d: rows %v, want %v", tt.orientation, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("orientation %d: rows %v, want %v", tt.orientation, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	small := labeled(2, 2)
+	if downscale(small, 4) != small {
+		t.Error("an image within the limit is not returned as it is")
+	}
+
+	wide := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			wide.SetRGBA(x, y, color.RGBA{R: uint8(x * 10), A: 255})
+		}
+	}
+	got := downscale(wide, 2)
+	if got.Bounds().Dx() != 2 || got.Bounds().Dy() != 1 {
+		t.Fatalf("size = %v, want 2x1", got.Bounds().Size())
+	}
+	if r := got.RGBAAt(0, 0).R; r != 5 {
+		t.Errorf("left pixel = %d, want the average 5", r)
+	}
+	if r := got.RGBAAt(1, 0).R; r != 25 {
+		t.Errorf("right pixel = %d, want the average 25", r)
+	}
+
+	tall := downscale(labeled(2, 6), 3)
+	if tall.Bounds().Dx() != 1 || tall.Bounds().Dy() != 3 {
+		t.Errorf("size = %v, want 1x3", tall.Bounds().Size())
+	}
+}
+
+func TestPrepareOrientsJPEG(t *testing.T) {
+	encoded := new(bytes.Buffer)
+	if err := jpeg.Encode(encoded, labeled(20, 30), nil); err != nil {
+		t.Fatal(err)
+	}
+	// the EXIF segment goes right after the start of image
+	data := exifJPEG("II", 6, encoded.Bytes()[2:])
+
+	out, kind, err := Prepare(data, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != TypeJPEG || cfg.Width != 30 || cfg.Height != 20 {
+		t.Errorf("got %s %dx%d, want image/jpeg 30x20", kind, cfg.Width, cfg.Height)
+	}
+}
+
+// pngHeader is only the signature and the header chunk of a PNG, enough for image.DecodeConfig
+func pngHeader(w int, h int) []byte {
+	ihdr := make([]byte, 4+13)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(w))
+	binary.BigEndian.PutUint32(ihdr[8:], uint32(h))
+	ihdr[12], ihdr[13] = 8, 6 // 8 bits RGBA
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, 13)
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestPrepareRefusesTooManyPixels(t *testing.T) {
+	if _, _, err := Prepare(pngHeader(4097, 4096), "image/png"); !errors.Is(err, entity.ErrImageTooLarge) {
+		t.Errorf("error = %v, want %v", err, entity.ErrImageTooLarge)
+	}
+}
@@ -0,0 +1,220 @@
+package driver
+
+import (
+	entity "go-jwt/internal/entity"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MigrateSqlServerDB creates the tables added after the original schema and adds the missing columns
+// and indexes to the existing tables. It never drops or changes anything that is already there.
+func MigrateSqlServerDB(db *gorm.DB) {
+	tables := []struct {
+		table string
+		model interface{}
+	}{
+		{"Schedule", &entity.Schedule{}},
+		{"Device_command", &entity.DeviceCommand{}},
+		{"Face_person", &entity.FacePerson{}},
+		{"Snapshot", &entity.Snapshot{}},
+		{"Camera", &entity.Camera{}},
+		{"Device_credential", &entity.DeviceCredential{}},
+		{"Device_type", &entity.DeviceType{}},
+		{"Dashboard_layout", &entity.DashboardLayout{}},
+		{"Room", &entity.Room{}},
+		{"Audit_entry", &entity.AuditEntry{}},
+		{"Audit_checkpoint", &entity.AuditCheckpoint{}},
+		{"Lockout", &entity.Lockout{}},
+	}
+	for _, table := range tables {
+		if err := db.Table(table.table).AutoMigrate(table.model); err != nil {
+			panic(err)
+		}
+	}
+
+	columns := []struct {
+		table string
+		model interface{}
+		field string
+	}{
+		{"House", &entity.House{}, "Latitude"},
+		{"House", &entity.House{}, "Longitude"},
+		{"Face_encoding", &entity.FaceEncoding{}, "Encoding_id"},
+		{"Face_encoding", &entity.FaceEncoding{}, "Person_id"},
+		{"Face_encoding", &entity.FaceEncoding{}, "Created_at"},
+		{"Iot_device", &entity.Device{}, "Last_seen"},
+		{"Iot_device", &entity.Device{}, "Online"},
+		{"Data_record", &entity.DataRecord{}, "Anomaly"},
+		{"Data_record", &entity.DataRecord{}, "Anomaly_reason"},
+		{"Iot_device", &entity.Device{}, "Room_id"},
+		{"Activity_log", &entity.ActivityLog{}, "Device_id"},
+		{"Activity_log", &entity.ActivityLog{}, "Actor_type"},
+		{"Activity_log", &entity.ActivityLog{}, "Actor_id"},
+		{"Activity_log", &entity.ActivityLog{}, "Actor_name"},
+		{"Activity_log", &entity.ActivityLog{}, "Actor_source"},
+		{"Activity_log", &entity.ActivityLog{}, "Event_code"},
+		{"Activity_log", &entity.ActivityLog{}, "Params"},
+		{"Send", &entity.Send{}, "Read_at"},
+		{"Send", &entity.Send{}, "Archived_at"},
+		{"Device_type", &entity.DeviceType{}, "State_commands"},
+		{"Device_type", &entity.DeviceType{}, "Routes"},
+	}
+	for _, column := range columns {
+		migrator := db.Table(column.table).Migrator()
+		if migrator.HasColumn(column.model, column.field) {
+			continue
+		}
+		if err := migrator.AddColumn(column.model, column.field); err != nil {
+			panic(err)
+		}
+	}
+
+	moveReadState(db)
+	keepNotificationReadDefault(db)
+	fixAnomalyColumn(db)
+
+	indexes := []struct {
+		table string
+		model interface{}
+		name  string
+	}{
+		{"Activity_log", &entity.ActivityLog{}, "idx_activity_log_house_time"},
+		{"Activity_log", &entity.ActivityLog{}, "idx_activity_log_device"},
+	}
+	for _, index := range indexes {
+		migrator := db.Table(index.table).Migrator()
+		if migrator.HasIndex(index.model, index.name) {
+			continue
+		}
+		if err := migrator.CreateIndex(index.model, index.name); err != nil {
+			panic(err)
+		}
+	}
+
+	seedDeviceTypes(db)
+}
+
+// moveReadState moves the read state from the Notification, shared by its recipients, to the Send of each
+// recipient. The column is added and filled in one transaction, a migration stopped between the two would
+// leave a column that is never filled.
+func moveReadState(db *gorm.DB) {
+	if db.Table("Send").Migrator().HasColumn(&entity.Send{}, "Read") {
+		return
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		panic(tx.Error)
+	}
+	if err := tx.Table("Send").Migrator().AddColumn(&entity.Send{}, "Read"); err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+	err := tx.Exec(`UPDATE "Send" SET "Read" = ISNULL(Notification."Read", 0) FROM "Send" JOIN Notification ON "Send".Notification_id = Notification.Notification_id`).Error
+	if err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		panic(err)
+	}
+}
+
+// keepNotificationReadDefault gives the old Read column of the Notification table a default, the notifications
+// are created without it now that the read state is on the Send
+func keepNotificationReadDefault(db *gorm.DB) {
+	err := db.Exec(`IF NOT EXISTS (SELECT 1 FROM sys.default_constraints
+		WHERE parent_object_id = OBJECT_ID('Notification') AND COL_NAME(parent_object_id, parent_column_id) = 'Read')
+		ALTER TABLE Notification ADD CONSTRAINT DF_Notification_Read DEFAULT 0 FOR "Read"`).Error
+	if err != nil {
+		panic(err)
+	}
+}
+
+// fixAnomalyColumn fills and closes the Anomaly column of Data_record. It was first added nullable without a
+// default, the readings of that time are NULL and the queries that leave the anomalies out skipped them.
+func fixAnomalyColumn(db *gorm.DB) {
+	columnTypes, err := db.Table("Data_record").Migrator().ColumnTypes(&entity.DataRecord{})
+	if err != nil {
+		panic(err)
+	}
+	nullable := false
+	for _, column := range columnTypes {
+		if strings.EqualFold(column.Name(), "Anomaly") {
+			isNullable, ok := column.Nullable()
+			nullable = ok && isNullable
+		}
+	}
+	if !nullable {
+		return
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		panic(tx.Error)
+	}
+	statements := []string{
+		`UPDATE Data_record SET Anomaly = 0 WHERE Anomaly IS NULL`,
+		`ALTER TABLE Data_record ALTER COLUMN Anomaly bit NOT NULL`,
+		`ALTER TABLE Data_record ADD CONSTRAINT DF_Data_record_Anomaly DEFAULT 0 FOR Anomaly`,
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		panic(err)
+	}
+}
+
+// seedDeviceTypes adds the device types the backend was written for. A type already there keeps what the
+// operators set in the database, it only gets the columns added after it was seeded that are still empty.
+func seedDeviceTypes(db *gorm.DB) {
+	float := func(value float64) *float64 { return &value }
+	never := 0
+	deviceTypes := []entity.DeviceType{
+		{Name: "Temperature", Value_kind: entity.ValueNumber, Unit: "°C", Min_value: float(0), Max_value: float(100)},
+		{Name: "Humidity", Value_kind: entity.ValueNumber, Unit: "%", Min_value: float(0), Max_value: float(100)},
+		{Name: "Light", Value_kind: entity.ValueInteger, Unit: "level", Min_value: float(0), Max_value: float(4),
+			Commands: []string{"on", "off", "level"}, Value_command: "level", State_commands: []string{"off", "on"},
+			Routes: map[string]entity.CommandRoute{
+				"on":    {Feed: "iot-alarm", Webhook: "Ye9oEbz9VvPgzjLYzjz7dDC8R1dL", Payload: "Alarm On"},
+				"off":   {Feed: "iot-alarm", Webhook: "Ye9oEbz9VvPgzjLYzjz7dDC8R1dL", Payload: "Alarm Off"},
+				"level": {Feed: "iot-state", Webhook: "YUgssBNR6j1J24jF6RDYG71QqH4c", Payload: "{value}"},
+			}},
+		{Name: "Fan", Value_kind: entity.ValueInteger, Unit: "%", Min_value: float(0), Max_value: float(100),
+			Commands: []string{"on", "off", "speed"}, Value_command: "speed", State_commands: []string{"off", "on"},
+			Routes: map[string]entity.CommandRoute{
+				"on":    {Feed: "iot-fan", Webhook: "9xJ4R9ZM7A9tKEeJcaJh9rS7t6L5", Payload: "Fan On"},
+				"off":   {Feed: "iot-fan", Webhook: "9xJ4R9ZM7A9tKEeJcaJh9rS7t6L5", Payload: "Fan Off"},
+				"speed": {Feed: "iot-fanspeed", Webhook: "GDfmkBYDyWBUV6A6M17stLHytSEM", Payload: "{value}"},
+			}},
+		{Name: "Door", Value_kind: entity.ValueEnum, States: []string{"closed", "open"},
+			Commands: []string{"open", "close"}, State_commands: []string{"close", "open"},
+			Routes: map[string]entity.CommandRoute{
+				"open":  {Feed: "iot-door", Webhook: "iMQFZUbNRJPM5ZCzRN4ped6GbL4W", Payload: "Open Door"},
+				"close": {Feed: "iot-door", Webhook: "iMQFZUbNRJPM5ZCzRN4ped6GbL4W", Payload: "Close Door"},
+			}},
+		// a camera only calls when someone is at the door
+		{Name: "Camera", Value_kind: entity.ValueNone, Offline_after: &never},
+	}
+	fillEmpty := func(column string) clause.Assignment {
+		return clause.Assignment{Column: clause.Column{Name: column}, Value: clause.Expr{
+			SQL:  "ISNULL(?, ?)",
+			Vars: []interface{}{clause.Column{Table: "Device_type", Name: column}, clause.Column{Table: "excluded", Name: column}},
+		}}
+	}
+	for _, deviceType := range deviceTypes {
+		err := db.Table("Device_type").Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "Name"}},
+			DoUpdates: clause.Set{fillEmpty("State_commands"), fillEmpty("Routes")},
+		}).Create(&deviceType).Error
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"errors"
+	entity "go-jwt/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+// AuditRepository only appends to the audit log, the entries are never updated or deleted
+type AuditRepository interface {
+	CreateAuditEntry(entry *entity.AuditEntry) error
+	GetLastAuditEntry(houseID int) (*entity.AuditEntry, error)
+	GetAuditEntries(houseID int, afterSeq int64, limit int) ([]entity.AuditEntry, error)
+	GetAuditHouses() ([]int, error)
+	CreateCheckpoint(checkpoint *entity.AuditCheckpoint) error
+	GetCheckpoints(houseID int) ([]entity.AuditCheckpoint, error)
+	GetLastCheckpoint(houseID int) (*entity.AuditCheckpoint, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepo(db *gorm.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// CreateAuditEntry returns ErrAuditSeqTaken when the unique index of House_id and Seq refused the entry,
+// another process appended to the house at the same time
+func (r *auditRepository) CreateAuditEntry(entry *entity.AuditEntry) error {
+	err := r.db.Table("Audit_entry").Create(entry).Error
+	if err == nil {
+		return nil
+	}
+	var taken int64
+	if r.db.Table("Audit_entry").Where("House_id = ? and Seq = ?", entry.House_id, entry.Seq).Count(&taken).Error == nil && taken > 0 {
+		return entity.ErrAuditSeqTaken
+	}
+	return err
+}
+
+// GetLastAuditEntry returns nil when the house has no entry yet
+func (r *auditRepository) GetLastAuditEntry(houseID int) (*entity.AuditEntry, error) {
+	entry := entity.AuditEntry{}
+	err := r.db.Table("Audit_entry").Where("House_id = ?", houseID).Order("Seq desc").First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetAuditEntries returns the entries of the house after afterSeq, in the order of the chain
+func (r *auditRepository) GetAuditEntries(houseID int, afterSeq int64, limit int) ([]entity.AuditEntry, error) {
+	var entries []entity.AuditEntry
+	err := r.db.Table("Audit_entry").Where("House_id = ? and Seq > ?", houseID, afterSeq).Order("Seq").Limit(limit).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetAuditHouses returns the houses that have an audit log
+func (r *auditRepository) GetAuditHouses() ([]int, error) {
+	var houseIDs []int
+	if err := r.db.Table("Audit_entry").Distinct("House_id").Pluck("House_id", &houseIDs).Error; err != nil {
+		return nil, err
+	}
+	return houseIDs, nil
+}
+
+func (r *auditRepository) CreateCheckpoint(checkpoint *entity.AuditCheckpoint) error {
+	return r.db.Table("Audit_checkpoint").Create(checkpoint).Error
+}
+
+func (r *auditRepository) GetCheckpoints(houseID int) ([]entity.AuditCheckpoint, error) {
+	var checkpoints []entity.AuditCheckpoint
+	if err := r.db.Table("Audit_checkpoint").Where("House_id = ?", houseID).Order("Seq").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// GetLastCheckpoint returns nil when the house has no checkpoint yet
+func (r *auditRepository) GetLastCheckpoint(houseID int) (*entity.AuditCheckpoint, error) {
+	checkpoint := entity.AuditCheckpoint{}
+	err := r.db.Table("Audit_checkpoint").Where("House_id = ?", houseID).Order("Seq desc").First(&checkpoint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"errors"
+	entity "go-jwt/internal/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CommandRepository interface {
+	CreateCommand(command *entity.DeviceCommand) error
+	UpdateCommand(command *entity.DeviceCommand) error
+	SupersedeCommands(houseID int, feed string, commandID int) error
+	GetPendingCommands(now time.Time) ([]entity.DeviceCommand, error)
+	GetCommandsByHouseID(houseID int, status string, roomID *int, limit int) ([]entity.DeviceCommand, error)
+	GetCommandByID(houseID int, commandID int) (*entity.DeviceCommand, error)
+	GetDeviceIDByType(houseID int, deviceType string) (int, error)
+	GetDataRecordsSince(deviceID int, since time.Time) ([]entity.DataRecord, error)
+	CreateActivityLog(activityLog *entity.ActivityLog) error
+}
+
+type commandRepository struct {
+	db *gorm.DB
+}
+
+func NewCommandRepo(db *gorm.DB) CommandRepository {
+	return &commandRepository{
+		db: db,
+	}
+}
+
+func (r *commandRepository) CreateCommand(command *entity.DeviceCommand) error {
+	return r.db.Table("Device_command").Create(command).Error
+}
+
+func (r *commandRepository) UpdateCommand(command *entity.DeviceCommand) error {
+	return r.db.Table("Device_command").Where("Command_id = ?", command.ID).Select("*").Omit("Command_id").Updates(command).Error
+}
+
+// SupersedeCommands fails the pending commands on the feed that are older than commandID,
+// the device must not be moved back to an older value by their retries
+func (r *commandRepository) SupersedeCommands(houseID int, feed string, commandID int) error {
+	return r.db.Table("Device_command").
+		Where("House_id = ? and Feed = ? and Command_id < ? and Status in ?", houseID, feed, commandID, []string{entity.CommandQueued, entity.CommandSent}).
+		Updates(map[string]interface{}{
+			"Status":     entity.CommandFailed,
+			"Last_error": "superseded by a newer command",
+		}).Error
+}
+
+// GetPendingCommands returns the queued commands that are due and all the sent commands waiting for their echo
+func (r *commandRepository) GetPendingCommands(now time.Time) ([]entity.DeviceCommand, error) {
+	var commands []entity.DeviceCommand
+	err := r.db.Table("Device_command").
+		Where("(Status = ? and Next_attempt_at <= ?) or Status = ?", entity.CommandQueued, now, entity.CommandSent).
+		Order("Command_id").Find(&commands).Error
+	if err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+// GetCommandsByHouseID returns the latest commands first, roomID keeps the commands to the devices now in the room
+func (r *commandRepository) GetCommandsByHouseID(houseID int, status string, roomID *int, limit int) ([]entity.DeviceCommand, error) {
+	var commands []entity.DeviceCommand
+	query := r.db.Table("Device_command").Where("House_id = ?", houseID)
+	if status != "" {
+		query = query.Where("Status = ?", status)
+	}
+	if roomID != nil {
+		query = query.Where("Device_id IN (?)", r.db.Table("Iot_device").Select("Device_id").Where("Room_id = ?", *roomID))
+	}
+	if err := query.Order("Command_id DESC").Limit(limit).Find(&commands).Error; err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+func (r *commandRepository) GetCommandByID(houseID int, commandID int) (*entity.DeviceCommand, error) {
+	command := entity.DeviceCommand{}
+	err := r.db.Table("Device_command").Where("House_id = ? and Command_id = ?", houseID, commandID).First(&command).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrCommandNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &command, nil
+}
+
+// GetDeviceIDByType returns the first device of the type in the house, or 0 if there is none
+func (r *commandRepository) GetDeviceIDByType(houseID int, deviceType string) (int, error) {
+	var ids []int
+	err := r.db.Table("Iot_device").Where("House_id = ? and Device_type = ?", houseID, deviceType).Order("Device_id").Limit(1).Pluck("Device_id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return ids[0], nil
+}
+
+func (r *commandRepository) CreateActivityLog(activityLog *entity.ActivityLog) error {
+	return r.db.Table("Activity_log").Create(activityLog).Error
+}
+
+// GetDataRecordsSince returns the readings the device sent after since, the anomalies left out
+func (r *commandRepository) GetDataRecordsSince(deviceID int, since time.Time) ([]entity.DataRecord, error) {
+	var records []entity.DataRecord
+	err := r.db.Table("Data_record").Where("Device_id = ? and Date_and_time > ? and Anomaly = ?", deviceID, since, false).
+		Order("Date_and_time").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	entity "go-jwt/internal/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CredentialRepository interface {
+	ReplaceCredentials(credential *entity.DeviceCredential) error
+	GetCredentials(deviceID int) ([]entity.DeviceCredential, error)
+	DeleteCredentials(deviceID int) (int64, error)
+	GetCredentialByHash(keyHash string) (*entity.DeviceCredential, error)
+	TouchCredential(credentialID int, t time.Time) error
+	GetDeviceByID(deviceID int) (*entity.Device, error)
+}
+
+type credentialRepository struct {
+	db *gorm.DB
+}
+
+func NewCredentialRepo(db *gorm.DB) CredentialRepository {
+	return &credentialRepository{
+		db: db,
+	}
+}
+
+// ReplaceCredentials creates the credential and deletes the other credentials of the device
+func (r *credentialRepository) ReplaceCredentials(credential *entity.DeviceCredential) error {
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Table("Device_credential").Where("Device_id = ?", credential.Device_id).Delete(&entity.DeviceCredential{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Table("Device_credential").Create(credential).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (r *credentialRepository) GetCredentials(deviceID int) ([]entity.DeviceCredential, error) {
+	var credentials []entity.DeviceCredential
+	if err := r.db.Table("Device_credential").Where("Device_id = ?", deviceID).Order("Created_at desc").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// DeleteCredentials returns how many credentials the device had
+func (r *credentialRepository) DeleteCredentials(deviceID int) (int64, error) {
+	result := r.db.Table("Device_credential").Where("Device_id = ?", deviceID).Delete(&entity.DeviceCredential{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *credentialRepository) GetCredentialByHash(keyHash string) (*entity.DeviceCredential, error) {
+	credential := entity.DeviceCredential{}
+	err := r.db.Table("Device_credential").Where("Key_hash = ?", keyHash).First(&credential).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrInvalidDeviceKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *credentialRepository) TouchCredential(credentialID int, t time.Time) error {
+	return r.db.Table("Device_credential").Where("Credential_id = ?", credentialID).Update("Last_used_at", t).Error
+}
+
+func (r *credentialRepository) GetDeviceByID(deviceID int) (*entity.Device, error) {
+	device := entity.Device{}
+	if err := r.db.Table("Iot_device").Where("Device_id = ?", deviceID).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
@@ -1,20 +1,42 @@
 package repository
 
 import (
+	"errors"
 	entity "go-jwt/internal/entity"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DeviceRepository interface {
-	UpdateTemperature(id int, temperature float64) error
-	UpdateHumidity(id int, humid float64) error
-	UpdateFanSpeed(id int, speed int) error
-	UpdateDevice(houseID int, deviceID int, deviceType string, data float64, state bool) error
-	UpdateFaceEncodings(houseID int, faceEncode string) error
+	GetDevices(houseID int, roomID *int) ([]entity.Device, error)
+	MarkSeen(deviceID int, t time.Time) error
+	GetOnlineDevices() ([]entity.Device, error)
+	MarkOffline(deviceID int, seenBefore time.Time) (bool, error)
+	CreateDataRecords(deviceID int, records []entity.DataRecord) (int64, error)
+	GetDataStats(deviceID int, from time.Time, to time.Time) (*entity.DataStats, error)
+	GetDeviceByID(deviceID int) (*entity.Device, error)
+	UpdateFaceEncodings(houseID int, personID *int, faceEncode string) error
 	GetFaceEncoding(houseID int) ([]string, error)
+	GetFaceEncodings(houseID int) ([]entity.FaceEncoding, error)
+	AssignFaceEncoding(houseID int, encodingID int, personID *int) error
+	DeleteFaceEncoding(houseID int, encodingID int) error
+	CreateFacePerson(person *entity.FacePerson) error
+	GetFacePeople(houseID int) ([]entity.FacePerson, error)
+	GetFacePerson(houseID int, personID int) (*entity.FacePerson, error)
+	UpdateFacePerson(person *entity.FacePerson) error
+	DeleteFacePerson(houseID int, personID int) error
 	CreateActivityLog(activityLog *entity.ActivityLog) error
+	CreateSnapshot(snapshot *entity.Snapshot) error
+	GetSnapshots(houseID int, limit int) ([]entity.Snapshot, error)
+	GetSnapshot(houseID int, snapshotID int) (*entity.Snapshot, error)
+	GetSnapshotsBefore(t time.Time, limit int) ([]entity.Snapshot, error)
+	DeleteSnapshot(snapshotID int) error
+	CreateCamera(camera *entity.Camera, credential *entity.DeviceCredential) error
+	GetCameras(houseID int) ([]entity.Camera, error)
+	GetCamera(houseID int, deviceID int) (*entity.Camera, error)
+	UpdateCamera(camera *entity.Camera) error
 }
 
 type deviceRepository struct {
@@ -27,92 +49,337 @@ func NewDeviceRepo(db *gorm.DB) DeviceRepository {
 	}
 }
 
-func (r *deviceRepository) UpdateTemperature(id int, temperature float64) error {
+// CreateDataRecords writes the records of the device in one transaction, and sets the current data of the
+// device to the latest normal record when it is newer than the records already there. The records already
+// written, e.g. by an earlier try of the same batch, are skipped. It returns how many records were written.
+func (r *deviceRepository) CreateDataRecords(deviceID int, records []entity.DataRecord) (int64, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+	var latest *entity.DataRecord
+	for i := range records {
+		if !records[i].Anomaly && (latest == nil || records[i].Time.After(latest.Time)) {
+			latest = &records[i]
+		}
+	}
+
 	tx := r.db.Begin()
 	if tx.Error != nil {
-		return tx.Error
+		return 0, tx.Error
 	}
-	if err := tx.Table("Iot_device").Where("House_id = ? and Device_type = ?", id, "Temperature").Update("Current_data", temperature).Error; err != nil {
+	var stored []entity.DataRecord
+	if err := tx.Table("Data_record").Where("Device_id = ? and Anomaly = ?", deviceID, false).Order("Date_and_time DESC").Limit(1).Find(&stored).Error; err != nil {
 		tx.Rollback()
-		return err
+		return 0, err
 	}
-	return tx.Commit().Error
+	result := tx.Table("Data_record").Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(records, 200)
+	if result.Error != nil {
+		tx.Rollback()
+		return 0, result.Error
+	}
+	if latest != nil && (len(stored) == 0 || latest.Time.After(stored[0].Time)) {
+		if err := tx.Table("Iot_device").Where("Device_id = ?", deviceID).Update("Current_data", latest.Device_data).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return result.RowsAffected, tx.Commit().Error
 }
 
-func (r *deviceRepository) UpdateHumidity(id int, humid float64) error {
-	tx := r.db.Begin()
-	if tx.Error != nil {
-		return tx.Error
+func (r *deviceRepository) GetDataStats(deviceID int, from time.Time, to time.Time) (*entity.DataStats, error) {
+	stats := entity.DataStats{Device_id: deviceID, From: from, To: to}
+	err := r.db.Table("Data_record").
+		Where("Device_id = ? and Date_and_time >= ? and Date_and_time < ? and Anomaly = ?", deviceID, from, to, false).
+		Select("COUNT(*) as count, MIN(Device_data) as min, MAX(Device_data) as max, AVG(Device_data) as avg").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
 	}
-	if err := tx.Table("Iot_device").Where("House_id = ? and Device_type = ?", id, "Humidity").Update("Current_data", humid).Error; err != nil {
-		tx.Rollback()
-		return err
+	err = r.db.Table("Data_record").
+		Where("Device_id = ? and Date_and_time >= ? and Date_and_time < ? and Anomaly = ?", deviceID, from, to, true).
+		Count(&stats.Anomalies).Error
+	if err != nil {
+		return nil, err
 	}
-	return tx.Commit().Error
+	return &stats, nil
 }
 
-func (r *deviceRepository) UpdateFanSpeed(id int, speed int) error {
+// GetDevices returns the devices of the house, only those of the room when roomID is not nil
+func (r *deviceRepository) GetDevices(houseID int, roomID *int) ([]entity.Device, error) {
+	var devices []entity.Device
+	query := r.db.Table("Iot_device").Where("House_id = ?", houseID)
+	if roomID != nil {
+		query = query.Where("Room_id = ?", *roomID)
+	}
+	if err := query.Order("Device_id").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (r *deviceRepository) MarkSeen(deviceID int, t time.Time) error {
+	return r.db.Table("Iot_device").Where("Device_id = ?", deviceID).Updates(map[string]interface{}{
+		"Last_seen": t,
+		"Online":    true,
+	}).Error
+}
+
+func (r *deviceRepository) GetOnlineDevices() ([]entity.Device, error) {
+	var devices []entity.Device
+	if err := r.db.Table("Iot_device").Where("Online = ?", true).Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// MarkOffline returns false when the device was seen again since seenBefore, it is left online
+func (r *deviceRepository) MarkOffline(deviceID int, seenBefore time.Time) (bool, error) {
+	result := r.db.Table("Iot_device").Where("Device_id = ? and Online = ? and Last_seen < ?", deviceID, true, seenBefore).Update("Online", false)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *deviceRepository) GetDeviceByID(deviceID int) (*entity.Device, error) {
+	device := entity.Device{}
+	if err := r.db.Table("Iot_device").Where("Device_id = ?", deviceID).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+func (r *deviceRepository) UpdateFaceEncodings(houseID int, personID *int, faceEncode string) error {
+	// the combination of both face_encode and house_id is primary key
 	tx := r.db.Begin()
 	if tx.Error != nil {
 		return tx.Error
 	}
-	if err := tx.Table("Iot_device").Where("House_id = ? and Device_type = ?", id, "Fan").Update("Current_data", speed).Error; err != nil {
+	if err := tx.Table("Face_encoding").Create(map[string]interface{}{
+		"Face_encoding": faceEncode,
+		"House_id":      houseID,
+		"Person_id":     personID,
+		"Created_at":    time.Now(),
+	}).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 	return tx.Commit().Error
 }
 
-func (r *deviceRepository) UpdateDevice(houseID int, deviceID int, deviceType string, data float64, state bool) error {
+func (r *deviceRepository) GetFaceEncoding(houseID int) ([]string, error) {
+	// get the face_encodings of the house (know that there is one it is NULL convert to empty string)
+	var faceEncodings []string
+	if err := r.db.Table("Face_encoding").Where("House_id = ? AND Face_encoding IS NOT NULL", houseID).Pluck("Face_encoding", &faceEncodings).Error; err != nil {
+		return nil, err
+	}
+	return faceEncodings, nil
+}
+
+func (r *deviceRepository) GetFaceEncodings(houseID int) ([]entity.FaceEncoding, error) {
+	var faceEncodings []entity.FaceEncoding
+	if err := r.db.Table("Face_encoding").Where("House_id = ? AND Face_encoding IS NOT NULL", houseID).Order("Encoding_id").Find(&faceEncodings).Error; err != nil {
+		return nil, err
+	}
+	return faceEncodings, nil
+}
+
+func (r *deviceRepository) AssignFaceEncoding(houseID int, encodingID int, personID *int) error {
+	result := r.db.Table("Face_encoding").Where("House_id = ? and Encoding_id = ?", houseID, encodingID).Update("Person_id", personID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrFaceEncodingNotFound
+	}
+	return nil
+}
+
+func (r *deviceRepository) DeleteFaceEncoding(houseID int, encodingID int) error {
+	result := r.db.Table("Face_encoding").Where("House_id = ? and Encoding_id = ?", houseID, encodingID).Delete(&entity.FaceEncoding{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrFaceEncodingNotFound
+	}
+	return nil
+}
+
+func (r *deviceRepository) CreateFacePerson(person *entity.FacePerson) error {
+	return r.db.Table("Face_person").Create(person).Error
+}
+
+func (r *deviceRepository) GetFacePeople(houseID int) ([]entity.FacePerson, error) {
+	var people []entity.FacePerson
+	if err := r.db.Table("Face_person").Where("House_id = ?", houseID).Order("Person_id").Find(&people).Error; err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+func (r *deviceRepository) GetFacePerson(houseID int, personID int) (*entity.FacePerson, error) {
+	person := entity.FacePerson{}
+	err := r.db.Table("Face_person").Where("House_id = ? and Person_id = ?", houseID, personID).First(&person).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrFacePersonNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+func (r *deviceRepository) UpdateFacePerson(person *entity.FacePerson) error {
+	result := r.db.Table("Face_person").Where("House_id = ? and Person_id = ?", person.House_id, person.ID).Updates(map[string]interface{}{
+		"Name":    person.Name,
+		"User_id": person.User_id,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrFacePersonNotFound
+	}
+	return nil
+}
+
+// DeleteFacePerson also deletes the encodings of the person, the face no longer opens the door
+func (r *deviceRepository) DeleteFacePerson(houseID int, personID int) error {
 	tx := r.db.Begin()
 	if tx.Error != nil {
 		return tx.Error
 	}
-	if err := tx.Table("Iot_device").Where("House_id = ? and Device_id = ?", houseID, deviceID).Update("Current_data", data).Error; err != nil {
+	if err := tx.Table("Face_encoding").Where("House_id = ? and Person_id = ?", houseID, personID).Delete(&entity.FaceEncoding{}).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
-	if err := tx.Table("Data_record").Create(map[string]interface{}{
-		"Device_id":     deviceID,
-		"Date_and_time": time.Now(),
-		"Device_data":   data,
-		"Device_state":  state,
-	}).Error; err != nil {
+	result := tx.Table("Face_person").Where("House_id = ? and Person_id = ?", houseID, personID).Delete(&entity.FacePerson{})
+	if result.Error != nil {
 		tx.Rollback()
-		return err
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return entity.ErrFacePersonNotFound
 	}
 	return tx.Commit().Error
 }
 
-func (r *deviceRepository) UpdateFaceEncodings(houseID int, faceEncode string) error {
-	// the combination of both face_encode and house_id is primary key
+func (d *deviceRepository) CreateActivityLog(activityLog *entity.ActivityLog) error {
+	err := d.db.Table("Activity_log").Create(activityLog).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *deviceRepository) CreateSnapshot(snapshot *entity.Snapshot) error {
+	return r.db.Table("Snapshot").Create(snapshot).Error
+}
+
+// GetSnapshots returns the latest snapshots of the house first
+func (r *deviceRepository) GetSnapshots(houseID int, limit int) ([]entity.Snapshot, error) {
+	var snapshots []entity.Snapshot
+	if err := r.db.Table("Snapshot").Where("House_id = ?", houseID).Order("Created_at desc").Limit(limit).Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (r *deviceRepository) GetSnapshot(houseID int, snapshotID int) (*entity.Snapshot, error) {
+	snapshot := entity.Snapshot{}
+	err := r.db.Table("Snapshot").Where("House_id = ? and Snapshot_id = ?", houseID, snapshotID).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// GetSnapshotsBefore returns the oldest snapshots taken before t, of every house
+func (r *deviceRepository) GetSnapshotsBefore(t time.Time, limit int) ([]entity.Snapshot, error) {
+	var snapshots []entity.Snapshot
+	if err := r.db.Table("Snapshot").Where("Created_at < ?", t).Order("Created_at").Limit(limit).Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (r *deviceRepository) DeleteSnapshot(snapshotID int) error {
+	return r.db.Table("Snapshot").Where("Snapshot_id = ?", snapshotID).Delete(&entity.Snapshot{}).Error
+}
+
+// CreateCamera creates the Iot_device of the camera, its Camera row and its credential, or none of them
+func (r *deviceRepository) CreateCamera(camera *entity.Camera, credential *entity.DeviceCredential) error {
 	tx := r.db.Begin()
 	if tx.Error != nil {
 		return tx.Error
 	}
-	if err := tx.Table("Face_encoding").Create(map[string]interface{}{
-		"Face_encoding": faceEncode,
-		"House_id":      houseID,
-	}).Error; err != nil {
+	device := entity.Device{Type: "Camera", Name: camera.Name, House_id: camera.House_id}
+	if err := tx.Table("Iot_device").Create(&device).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	camera.Device_id = device.ID
+	if err := tx.Table("Camera").Create(camera).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	credential.Device_id = device.ID
+	if err := tx.Table("Device_credential").Create(credential).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 	return tx.Commit().Error
 }
 
-func (r *deviceRepository) GetFaceEncoding(houseID int) ([]string, error) {
-	// get the face_encodings of the house (know that there is one it is NULL convert to empty string)
-	var faceEncodings []string
-	if err := r.db.Table("Face_encoding").Where("House_id = ? AND Face_encoding IS NOT NULL", houseID).Pluck("Face_encoding", &faceEncodings).Error; err != nil {
+func (r *deviceRepository) GetCameras(houseID int) ([]entity.Camera, error) {
+	var cameras []entity.Camera
+	err := r.db.Table("Camera").
+		Select("Camera.*, Iot_device.Name").
+		Joins("JOIN Iot_device ON Iot_device.Device_id = Camera.Device_id").
+		Where("Camera.House_id = ?", houseID).
+		Order("Camera.Device_id").
+		Find(&cameras).Error
+	if err != nil {
 		return nil, err
 	}
-	return faceEncodings, nil
+	return cameras, nil
 }
 
-func (d *deviceRepository) CreateActivityLog(activityLog *entity.ActivityLog) error {
-	err := d.db.Table("Activity_log").Create(activityLog).Error
+func (r *deviceRepository) GetCamera(houseID int, deviceID int) (*entity.Camera, error) {
+	camera := entity.Camera{}
+	err := r.db.Table("Camera").
+		Select("Camera.*, Iot_device.Name").
+		Joins("JOIN Iot_device ON Iot_device.Device_id = Camera.Device_id").
+		Where("Camera.House_id = ? and Camera.Device_id = ?", houseID, deviceID).
+		First(&camera).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrCameraNotFound
+	}
 	if err != nil {
+		return nil, err
+	}
+	return &camera, nil
+}
+
+func (r *deviceRepository) UpdateCamera(camera *entity.Camera) error {
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	result := tx.Table("Camera").Where("House_id = ? and Device_id = ?", camera.House_id, camera.Device_id).Update("Door_id", camera.Door_id)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return entity.ErrCameraNotFound
+	}
+	if err := tx.Table("Iot_device").Where("Device_id = ?", camera.Device_id).Update("Name", camera.Name).Error; err != nil {
+		tx.Rollback()
 		return err
 	}
-	return nil
+	return tx.Commit().Error
 }
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	entity "go-jwt/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+type DeviceTypeRepository interface {
+	GetDeviceTypes() ([]entity.DeviceType, error)
+}
+
+type deviceTypeRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceTypeRepo(db *gorm.DB) DeviceTypeRepository {
+	return &deviceTypeRepository{
+		db: db,
+	}
+}
+
+func (r *deviceTypeRepository) GetDeviceTypes() ([]entity.DeviceType, error) {
+	var deviceTypes []entity.DeviceType
+	if err := r.db.Table("Device_type").Order("Name").Find(&deviceTypes).Error; err != nil {
+		return nil, err
+	}
+	return deviceTypes, nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	entity "go-jwt/internal/entity"
+	"go-jwt/internal/ratelimit"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LockoutRepository is the ratelimit.Store of the failures and the lockouts in the database
+type LockoutRepository interface {
+	Fail(key string, now time.Time, threshold int, lockUntil time.Time) (bool, error)
+	LockedUntil(now time.Time, keys []string) (time.Time, error)
+	Reset(keys []string) error
+	Lockouts(now time.Time) ([]ratelimit.Lockout, error)
+	Clear(key string) (bool, error)
+	Prune(now time.Time) error
+}
+
+type lockoutRepository struct {
+	db *gorm.DB
+}
+
+func NewLockoutRepo(db *gorm.DB) LockoutRepository {
+	return &lockoutRepository{
+		db: db,
+	}
+}
+
+// Fail increments the failures in the database, so the failures of two instances add up. Only the instance
+// whose update takes the lock sees it locked.
+func (r *lockoutRepository) Fail(key string, now time.Time, threshold int, lockUntil time.Time) (bool, error) {
+	if err := r.increment(key); err != nil {
+		return false, err
+	}
+	result := r.db.Table("Lockout").
+		Where("Lock_key = ? and Failures >= ? and (Locked_until is null or Locked_until <= ?)", key, threshold, now).
+		Updates(map[string]interface{}{"Failures": 0, "Locked_until": lockUntil})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *lockoutRepository) increment(key string) error {
+	result := r.db.Table("Lockout").Where("Lock_key = ?", key).Update("Failures", gorm.Expr("Failures + 1"))
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	if err := r.db.Table("Lockout").Create(&entity.Lockout{Key: key, Failures: 1}).Error; err != nil {
+		// another instance created the key in between
+		return r.db.Table("Lockout").Where("Lock_key = ?", key).Update("Failures", gorm.Expr("Failures + 1")).Error
+	}
+	return nil
+}
+
+func (r *lockoutRepository) LockedUntil(now time.Time, keys []string) (time.Time, error) {
+	var lockouts []entity.Lockout
+	err := r.db.Table("Lockout").Where("Lock_key in ? and Locked_until > ?", keys, now).
+		Order("Locked_until desc").Limit(1).Find(&lockouts).Error
+	if err != nil || len(lockouts) == 0 {
+		return time.Time{}, err
+	}
+	return *lockouts[0].Locked_until, nil
+}
+
+func (r *lockoutRepository) Reset(keys []string) error {
+	return r.db.Table("Lockout").Where("Lock_key in ? and Failures > 0", keys).Update("Failures", 0).Error
+}
+
+func (r *lockoutRepository) Lockouts(now time.Time) ([]ratelimit.Lockout, error) {
+	var rows []entity.Lockout
+	if err := r.db.Table("Lockout").Where("Locked_until > ?", now).Order("Lock_key").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	lockouts := []ratelimit.Lockout{}
+	for _, row := range rows {
+		lockouts = append(lockouts, ratelimit.Lockout{Key: row.Key, LockedUntil: *row.Locked_until})
+	}
+	return lockouts, nil
+}
+
+func (r *lockoutRepository) Clear(key string) (bool, error) {
+	result := r.db.Table("Lockout").Where("Lock_key = ?", key).Delete(&entity.Lockout{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Prune deletes the keys with no failure and no lockout left
+func (r *lockoutRepository) Prune(now time.Time) error {
+	return r.db.Table("Lockout").Where("Failures = 0 and (Locked_until is null or Locked_until <= ?)", now).
+		Delete(&entity.Lockout{}).Error
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"errors"
+	entity "go-jwt/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+type RoomRepository interface {
+	CreateRoom(room *entity.Room) error
+	GetRooms(houseID int) ([]entity.Room, error)
+	GetRoom(houseID int, roomID int) (*entity.Room, error)
+	RenameRoom(room *entity.Room) error
+	DeleteRoom(houseID int, roomID int) error
+	GetDevices(houseID int, roomID *int) ([]entity.Device, error)
+	SetDeviceRoom(houseID int, deviceID int, roomID *int) error
+}
+
+type roomRepository struct {
+	db *gorm.DB
+}
+
+func NewRoomRepo(db *gorm.DB) RoomRepository {
+	return &roomRepository{
+		db: db,
+	}
+}
+
+// roomExists tells whether another room of the house than roomID has the name, roomID is 0 for a new room
+func (r *roomRepository) roomExists(tx *gorm.DB, houseID int, roomID int, name string) (bool, error) {
+	var count int64
+	err := tx.Table("Room").Where("House_id = ? and Name = ? and Room_id <> ?", houseID, name, roomID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *roomRepository) CreateRoom(room *entity.Room) error {
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	exists, err := r.roomExists(tx, room.House_id, 0, room.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if exists {
+		tx.Rollback()
+		return entity.ErrRoomExists
+	}
+	if err := tx.Table("Room").Create(room).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (r *roomRepository) GetRooms(houseID int) ([]entity.Room, error) {
+	var rooms []entity.Room
+	if err := r.db.Table("Room").Where("House_id = ?", houseID).Order("Room_id").Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (r *roomRepository) GetRoom(houseID int, roomID int) (*entity.Room, error) {
+	room := entity.Room{}
+	err := r.db.Table("Room").Where("House_id = ? and Room_id = ?", houseID, roomID).First(&room).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+func (r *roomRepository) RenameRoom(room *entity.Room) error {
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	exists, err := r.roomExists(tx, room.House_id, room.ID, room.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if exists {
+		tx.Rollback()
+		return entity.ErrRoomExists
+	}
+	result := tx.Table("Room").Where("House_id = ? and Room_id = ?", room.House_id, room.ID).Update("Name", room.Name)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return entity.ErrRoomNotFound
+	}
+	return tx.Commit().Error
+}
+
+// DeleteRoom takes the devices out of the room before deleting it, the devices are kept
+func (r *roomRepository) DeleteRoom(houseID int, roomID int) error {
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Table("Iot_device").Where("House_id = ? and Room_id = ?", houseID, roomID).Update("Room_id", nil).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	result := tx.Table("Room").Where("House_id = ? and Room_id = ?", houseID, roomID).Delete(&entity.Room{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return entity.ErrRoomNotFound
+	}
+	return tx.Commit().Error
+}
+
+// GetDevices returns the devices of the house, only those of the room when roomID is not nil
+func (r *roomRepository) GetDevices(houseID int, roomID *int) ([]entity.Device, error) {
+	var devices []entity.Device
+	query := r.db.Table("Iot_device").Where("House_id = ?", houseID)
+	if roomID != nil {
+		query = query.Where("Room_id = ?", *roomID)
+	}
+	if err := query.Order("Device_id").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// SetDeviceRoom moves the device to the room, or out of any room when roomID is nil
+func (r *roomRepository) SetDeviceRoom(houseID int, deviceID int, roomID *int) error {
+	result := r.db.Table("Iot_device").Where("House_id = ? and Device_id = ?", houseID, deviceID).Update("Room_id", roomID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
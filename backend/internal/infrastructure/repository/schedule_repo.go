@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"errors"
+	entity "go-jwt/internal/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ScheduleRepository interface {
+	GetHouseByID(houseID int) (*entity.House, error)
+	UpdateHouseLocation(houseID int, latitude float64, longitude float64) error
+	CreateSchedule(schedule *entity.Schedule) error
+	GetSchedulesByHouseID(houseID int) ([]entity.Schedule, error)
+	GetScheduleByID(houseID int, scheduleID int) (*entity.Schedule, error)
+	UpdateSchedule(schedule *entity.Schedule) error
+	DeleteSchedule(houseID int, scheduleID int) error
+	GetDueSchedules(now time.Time) ([]entity.Schedule, error)
+	UpdateScheduleRun(scheduleID int, lastRun *time.Time, nextRun *time.Time, status string, enabled bool) error
+	CreateActivityLog(activityLog *entity.ActivityLog) error
+}
+
+type scheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepo(db *gorm.DB) ScheduleRepository {
+	return &scheduleRepository{
+		db: db,
+	}
+}
+
+func (r *scheduleRepository) GetHouseByID(houseID int) (*entity.House, error) {
+	house := entity.House{}
+	if err := r.db.Table("House").Where("House_id = ?", houseID).First(&house).Error; err != nil {
+		return nil, err
+	}
+	return &house, nil
+}
+
+func (r *scheduleRepository) UpdateHouseLocation(houseID int, latitude float64, longitude float64) error {
+	return r.db.Table("House").Where("House_id = ?", houseID).Updates(map[string]interface{}{
+		"Latitude":  latitude,
+		"Longitude": longitude,
+	}).Error
+}
+
+func (r *scheduleRepository) CreateSchedule(schedule *entity.Schedule) error {
+	return r.db.Table("Schedule").Create(schedule).Error
+}
+
+func (r *scheduleRepository) GetSchedulesByHouseID(houseID int) ([]entity.Schedule, error) {
+	var schedules []entity.Schedule
+	if err := r.db.Table("Schedule").Where("House_id = ?", houseID).Order("Schedule_id").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *scheduleRepository) GetScheduleByID(houseID int, scheduleID int) (*entity.Schedule, error) {
+	schedule := entity.Schedule{}
+	err := r.db.Table("Schedule").Where("House_id = ? and Schedule_id = ?", houseID, scheduleID).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *scheduleRepository) UpdateSchedule(schedule *entity.Schedule) error {
+	return r.db.Table("Schedule").Where("House_id = ? and Schedule_id = ?", schedule.House_id, schedule.ID).Select("*").Omit("Schedule_id").Updates(schedule).Error
+}
+
+func (r *scheduleRepository) DeleteSchedule(houseID int, scheduleID int) error {
+	result := r.db.Table("Schedule").Where("House_id = ? and Schedule_id = ?", houseID, scheduleID).Delete(&entity.Schedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (r *scheduleRepository) GetDueSchedules(now time.Time) ([]entity.Schedule, error) {
+	var schedules []entity.Schedule
+	if err := r.db.Table("Schedule").Where("Enabled = ? and Next_run <= ?", true, now).Order("Next_run").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *scheduleRepository) UpdateScheduleRun(scheduleID int, lastRun *time.Time, nextRun *time.Time, status string, enabled bool) error {
+	return r.db.Table("Schedule").Where("Schedule_id = ?", scheduleID).Updates(map[string]interface{}{
+		"Last_run":    lastRun,
+		"Next_run":    nextRun,
+		"Last_status": status,
+		"Enabled":     enabled,
+	}).Error
+}
+
+func (r *scheduleRepository) CreateActivityLog(activityLog *entity.ActivityLog) error {
+	return r.db.Table("Activity_log").Create(activityLog).Error
+}
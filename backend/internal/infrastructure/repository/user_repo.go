@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	entity "go-jwt/internal/entity"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -12,16 +15,36 @@ type UserRepository interface {
 	GetUserByUsername(username string) (*entity.User, error)
 	GetTempAndHumid(house_id int) (float64, float64, error)
 	GetHouseID(userID int) ([]int, error)
+	GetOwnerIDs(houseID int) ([]int, error)
+	IsHouseMember(userID int, houseID int) (bool, error)
 	GetHouseSettingByHouseID(house_id int) ([]entity.HouseSetting, error)
 	GetSetOfHouseSetting(house_id int, settingName string) ([]entity.Set, error)
-	GetActivityLogByHouseID(house_id int) ([]entity.ActivityLog, error)
+	HouseSettingExists(house_id int, settingName string) (bool, error)
+	GetActivityLogByHouseID(house_id int, roomID *int) ([]entity.ActivityLog, error)
+	GetActivityLog(houseID int, filter entity.ActivityFilter, after *entity.ActivityCursor, limit int) ([]entity.ActivityLog, error)
 	UpdateDeviceData(deviceID int, data float64, house_id int, setting string) error
 	UpdataDeviceState(deviceID int, state bool, house_id int, setting string) error
 	GetDashboardData(house_id int) (float64, float64, float64, float64, error)
 	UpdateSet(deviceID int, data float64, state bool, house_id int, setting string) error
 	UpdateManySets([]entity.Set) error
-	GetAllNotifications(userID int) ([]entity.Notification, error)
-	GetUnreadNotifications(userID int) ([]entity.Notification, error)
+	SelectHouseSetting(house_id int, settingName string) error
+	GetDevicesByHouseID(house_id int) ([]entity.Device, error)
+	GetHouseByID(houseID int) (*entity.House, error)
+	CountUnreadNotifications(userID int, houseID *int) (int64, error)
+	GetDashboardLayout(userID int, houseID int) (*entity.DashboardLayout, error)
+	GetRooms(houseID int) ([]entity.Room, error)
+	SaveDashboardLayout(layout *entity.DashboardLayout) error
+	DeleteDashboardLayout(userID int, houseID int) error
+	GetLatestDataRecord(deviceID int) (*entity.DataRecord, error)
+	CreateHouseSetting(setting *entity.HouseSetting, sets []entity.Set) error
+	RenameHouseSetting(house_id int, settingName string, newName string) error
+	ReplaceSets(house_id int, settingName string, sets []entity.Set) error
+	DeleteHouseSetting(house_id int, settingName string) error
+	GetNotifications(userID int, filter entity.NotificationFilter) ([]entity.Notification, error)
+	MarkNotificationRead(userID int, notificationID int, read bool, t time.Time) error
+	MarkAllNotificationsRead(userID int, houseID *int, t time.Time) (int64, error)
+	ArchiveNotification(userID int, notificationID int, t time.Time) error
+	DeleteNotification(userID int, notificationID int) error
 	CreateNotification(userID int, houseID int, notification *entity.Notification) error
 	CreateActivityLog(activityLog *entity.ActivityLog) error
 }
@@ -110,6 +133,31 @@ func (userRepo *userRepository) GetHouseID(userID int) ([]int, error) {
 	return houseIDs, nil
 }
 
+func (userRepo *userRepository) GetHouseByID(houseID int) (*entity.House, error) {
+	house := entity.House{}
+	if err := userRepo.db.Table("House").Where("House_id = ?", houseID).First(&house).Error; err != nil {
+		return nil, err
+	}
+	return &house, nil
+}
+
+func (userRepo *userRepository) IsHouseMember(userID int, houseID int) (bool, error) {
+	var count int64
+	if err := userRepo.db.Table("Own").Where("User_id = ? and House_id = ?", userID, houseID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (userRepo *userRepository) GetOwnerIDs(houseID int) ([]int, error) {
+	var userIDs []int
+	err := userRepo.db.Table("Own").Where("House_id = ?", houseID).Select("User_id").Scan(&userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
 func (userRepo *userRepository) GetHouseSettingByHouseID(house_id int) ([]entity.HouseSetting, error) {
 	var houseSettings []entity.HouseSetting
 	err := userRepo.db.Table("House_setting").Where("House_id = ?", house_id).Find(&houseSettings).Error
@@ -129,15 +177,68 @@ func (userRepo *userRepository) GetSetOfHouseSetting(house_id int, settingName s
 	return sets, nil
 }
 
-func (userRepo *userRepository) GetActivityLogByHouseID(house_id int) ([]entity.ActivityLog, error) {
+// GetActivityLogByHouseID returns the log of the house, roomID keeps the events of the devices now in the room
+func (userRepo *userRepository) GetActivityLogByHouseID(house_id int, roomID *int) ([]entity.ActivityLog, error) {
 	var activityLogs []entity.ActivityLog
-	err := userRepo.db.Table("Activity_log").Where("House_id = ?", house_id).Find(&activityLogs).Error
+	query := userRepo.db.Table("Activity_log").Where("House_id = ?", house_id)
+	if roomID != nil {
+		query = query.Where("Device_id IN (?)", userRepo.db.Table("Iot_device").Select("Device_id").Where("Room_id = ?", *roomID))
+	}
+	err := query.Order("Time DESC, Activity_id DESC").Find(&activityLogs).Error
 	if err != nil {
 		return nil, err
 	}
 	return activityLogs, nil
 }
 
+// GetActivityLog returns up to limit entries after the cursor, the newest first.
+// The house, the time and the id are the columns of idx_activity_log_house_time.
+func (userRepo *userRepository) GetActivityLog(houseID int, filter entity.ActivityFilter, after *entity.ActivityCursor, limit int) ([]entity.ActivityLog, error) {
+	query := userRepo.db.Table("Activity_log").Where("House_id = ?", houseID)
+	if filter.Device_id != nil {
+		query = query.Where("Device_id = ?", *filter.Device_id)
+	}
+	if filter.Room_id != nil {
+		query = query.Where("Device_id IN (?)", userRepo.db.Table("Iot_device").Select("Device_id").Where("Room_id = ?", *filter.Room_id))
+	}
+	if filter.Device != "" {
+		query = query.Where("Device = ?", filter.Device)
+	}
+	if filter.Actor != "" {
+		query = query.Where("Actor_type = ?", filter.Actor)
+	}
+	if filter.Actor_id != nil {
+		query = query.Where("Actor_id = ?", *filter.Actor_id)
+	}
+	if filter.Source != "" {
+		query = query.Where("Actor_source = ?", filter.Source)
+	}
+	if filter.Event != "" {
+		query = query.Where("Event_code = ?", filter.Event)
+	}
+	if filter.From != nil {
+		query = query.Where("Time >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("Time < ?", *filter.To)
+	}
+	if filter.Search != "" {
+		query = query.Where("LOWER(Type_of_event) LIKE ? ESCAPE '\\'", "%"+likeEscaper.Replace(strings.ToLower(filter.Search))+"%")
+	}
+	if after != nil {
+		query = query.Where("(Time < ? or (Time = ? and Activity_id < ?))", after.Time, after.Time, after.ID)
+	}
+
+	var activityLogs []entity.ActivityLog
+	if err := query.Order("Time DESC, Activity_id DESC").Limit(limit).Find(&activityLogs).Error; err != nil {
+		return nil, err
+	}
+	return activityLogs, nil
+}
+
+// likeEscaper makes a search match itself in a LIKE pattern, [ starts a character set on SQL Server
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "[", `\[`)
+
 func (userRepo *userRepository) UpdateDeviceData(deviceID int, data float64, house_id int, setting string) error {
 	err := userRepo.db.Table("Set").Where("House_id = ? and Name = ? and Device_id = ?", house_id, setting, deviceID).Update("Device_data", data).Error
 	if err != nil {
@@ -201,24 +302,332 @@ func (userRepo *userRepository) UpdateManySets(sets []entity.Set) error {
 	return nil
 }
 
-func (userRepo *userRepository) GetAllNotifications(userID int) ([]entity.Notification, error) {
-	var notifications []entity.Notification
-	err := userRepo.db.Table("Send").Where("User_id = ?", userID).Joins("JOIN Notification ON \"Send\".Notification_id = Notification.Notification_id").Find(&notifications).Error
+// SelectHouseSetting marks settingName as the only selected setting of the house
+func (userRepo *userRepository) SelectHouseSetting(house_id int, settingName string) error {
+	tx := userRepo.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	result := tx.Table("House_setting").Where("House_id = ? and Name = ?", house_id, settingName).Update("Selected", true)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return entity.ErrHouseSettingNotFound
+	}
+	err := tx.Table("House_setting").Where("House_id = ? and Name <> ?", house_id, settingName).Update("Selected", false).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (userRepo *userRepository) GetDevicesByHouseID(house_id int) ([]entity.Device, error) {
+	var devices []entity.Device
+	err := userRepo.db.Table("Iot_device").Where("House_id = ?", house_id).Order("Device_id").Find(&devices).Error
 	if err != nil {
 		return nil, err
 	}
-	return notifications, nil
+	return devices, nil
 }
 
-func (userRepo *userRepository) GetUnreadNotifications(userID int) ([]entity.Notification, error) {
-	var notifications []entity.Notification
-	err := userRepo.db.Table("Send").Where("User_id = ? and Read = ?", userID, false).Joins("JOIN Notification ON \"Send\".Notification_id = Notification.Notification_id").Find(&notifications).Error
+// GetLatestDataRecord returns nil without error when the device never sent data, the anomalies are left out
+func (userRepo *userRepository) GetLatestDataRecord(deviceID int) (*entity.DataRecord, error) {
+	var records []entity.DataRecord
+	err := userRepo.db.Table("Data_record").Where("Device_id = ? and Anomaly = ?", deviceID, false).Order("Date_and_time DESC").Limit(1).Find(&records).Error
 	if err != nil {
 		return nil, err
 	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+func (userRepo *userRepository) houseSettingExists(tx *gorm.DB, house_id int, settingName string) (bool, error) {
+	var count int64
+	err := tx.Table("House_setting").Where("House_id = ? and Name = ?", house_id, settingName).Count(&count).Error
+	return count > 0, err
+}
+
+func (userRepo *userRepository) HouseSettingExists(house_id int, settingName string) (bool, error) {
+	return userRepo.houseSettingExists(userRepo.db, house_id, settingName)
+}
+
+func (userRepo *userRepository) CreateHouseSetting(setting *entity.HouseSetting, sets []entity.Set) error {
+	tx := userRepo.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	exists, err := userRepo.houseSettingExists(tx, setting.House_id, setting.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if exists {
+		tx.Rollback()
+		return entity.ErrHouseSettingExists
+	}
+	if err := tx.Table("House_setting").Create(setting).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(sets) > 0 {
+		// Device_name is not a column of Set
+		if err := tx.Table("Set").Omit("Device_name").Create(&sets).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// RenameHouseSetting moves the sets and the schedules to the new name, the name is part of the primary key
+// so the setting is created again under the new name and the old one is deleted
+func (userRepo *userRepository) RenameHouseSetting(house_id int, settingName string, newName string) error {
+	tx := userRepo.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	setting := entity.HouseSetting{}
+	err := tx.Table("House_setting").Where("House_id = ? and Name = ?", house_id, settingName).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return entity.ErrHouseSettingNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	exists, err := userRepo.houseSettingExists(tx, house_id, newName)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if exists {
+		tx.Rollback()
+		return entity.ErrHouseSettingExists
+	}
+
+	setting.Name = newName
+	if err := tx.Table("House_setting").Create(&setting).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Table("Set").Where("House_id = ? and Name = ?", house_id, settingName).Update("Name", newName).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Table("Schedule").Where("House_id = ? and Setting_name = ?", house_id, settingName).Update("Setting_name", newName).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Table("House_setting").Where("House_id = ? and Name = ?", house_id, settingName).Delete(&entity.HouseSetting{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (userRepo *userRepository) ReplaceSets(house_id int, settingName string, sets []entity.Set) error {
+	tx := userRepo.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	exists, err := userRepo.houseSettingExists(tx, house_id, settingName)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !exists {
+		tx.Rollback()
+		return entity.ErrHouseSettingNotFound
+	}
+	if err := tx.Table("Set").Where("House_id = ? and Name = ?", house_id, settingName).Delete(&entity.Set{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(sets) > 0 {
+		if err := tx.Table("Set").Omit("Device_name").Create(&sets).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// DeleteHouseSetting refuses to delete a setting that a scene schedule still runs, the schedule goes first
+func (userRepo *userRepository) DeleteHouseSetting(house_id int, settingName string) error {
+	tx := userRepo.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	var schedules int64
+	if err := tx.Table("Schedule").Where("House_id = ? and Setting_name = ?", house_id, settingName).Count(&schedules).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if schedules > 0 {
+		tx.Rollback()
+		return fmt.Errorf("%w: delete or change its %d schedule(s) first", entity.ErrHouseSettingInUse, schedules)
+	}
+	if err := tx.Table("Set").Where("House_id = ? and Name = ?", house_id, settingName).Delete(&entity.Set{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	result := tx.Table("House_setting").Where("House_id = ? and Name = ?", house_id, settingName).Delete(&entity.HouseSetting{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return entity.ErrHouseSettingNotFound
+	}
+	return tx.Commit().Error
+}
+
+// notifications is the query of the notifications of the user, with the read state of the user
+func (userRepo *userRepository) notifications(userID int) *gorm.DB {
+	return userRepo.db.Table("Send").
+		Select("Notification.Notification_id, Notification.Description, Notification.Time, Notification.Title, "+
+			"\"Send\".\"Read\", \"Send\".House_id, \"Send\".Read_at, \"Send\".Archived_at").
+		Joins("JOIN Notification ON \"Send\".Notification_id = Notification.Notification_id").
+		Where("\"Send\".User_id = ?", userID)
+}
+
+// GetNotifications returns the newest notifications first
+func (userRepo *userRepository) GetNotifications(userID int, filter entity.NotificationFilter) ([]entity.Notification, error) {
+	query := userRepo.notifications(userID)
+	if filter.House_id != nil {
+		query = query.Where("\"Send\".House_id = ?", *filter.House_id)
+	}
+	if filter.Unread {
+		query = query.Where("\"Send\".\"Read\" = ?", false)
+	}
+	if filter.Archived {
+		query = query.Where("\"Send\".Archived_at IS NOT NULL")
+	} else {
+		query = query.Where("\"Send\".Archived_at IS NULL")
+	}
+
+	var notifications []entity.Notification
+	if err := query.Order("Notification.Time desc, Notification.Notification_id desc").Find(&notifications).Error; err != nil {
+		return nil, err
+	}
 	return notifications, nil
 }
 
+// CountUnreadNotifications counts the unread notifications that are not archived, of every house when houseID is nil
+func (userRepo *userRepository) CountUnreadNotifications(userID int, houseID *int) (int64, error) {
+	query := userRepo.db.Table("Send").Where("User_id = ? and \"Read\" = ? and Archived_at IS NULL", userID, false)
+	if houseID != nil {
+		query = query.Where("House_id = ?", *houseID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (userRepo *userRepository) MarkNotificationRead(userID int, notificationID int, read bool, t time.Time) error {
+	values := map[string]interface{}{"Read": read, "Read_at": nil}
+	if read {
+		values["Read_at"] = t
+	}
+	result := userRepo.db.Table("Send").Where("User_id = ? and Notification_id = ?", userID, notificationID).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead returns how many notifications were unread
+func (userRepo *userRepository) MarkAllNotificationsRead(userID int, houseID *int, t time.Time) (int64, error) {
+	query := userRepo.db.Table("Send").Where("User_id = ? and \"Read\" = ?", userID, false)
+	if houseID != nil {
+		query = query.Where("House_id = ?", *houseID)
+	}
+	result := query.Updates(map[string]interface{}{"Read": true, "Read_at": t})
+	return result.RowsAffected, result.Error
+}
+
+func (userRepo *userRepository) ArchiveNotification(userID int, notificationID int, t time.Time) error {
+	result := userRepo.db.Table("Send").Where("User_id = ? and Notification_id = ?", userID, notificationID).Update("Archived_at", t)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrNotificationNotFound
+	}
+	return nil
+}
+
+// DeleteNotification deletes the notification of the user, and the notification itself once no one else has it
+func (userRepo *userRepository) DeleteNotification(userID int, notificationID int) error {
+	tx := userRepo.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	result := tx.Table("Send").Where("User_id = ? and Notification_id = ?", userID, notificationID).Delete(&entity.Send{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return entity.ErrNotificationNotFound
+	}
+	var recipients int64
+	if err := tx.Table("Send").Where("Notification_id = ?", notificationID).Count(&recipients).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if recipients == 0 {
+		if err := tx.Table("Notification").Where("Notification_id = ?", notificationID).Delete(&entity.Notification{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (userRepo *userRepository) GetDashboardLayout(userID int, houseID int) (*entity.DashboardLayout, error) {
+	layout := entity.DashboardLayout{}
+	err := userRepo.db.Table("Dashboard_layout").Where("User_id = ? and House_id = ?", userID, houseID).First(&layout).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrDashboardLayoutNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+func (userRepo *userRepository) GetRooms(houseID int) ([]entity.Room, error) {
+	var rooms []entity.Room
+	if err := userRepo.db.Table("Room").Where("House_id = ?", houseID).Order("Room_id").Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// SaveDashboardLayout creates the layout of the user or replaces it
+func (userRepo *userRepository) SaveDashboardLayout(layout *entity.DashboardLayout) error {
+	return userRepo.db.Table("Dashboard_layout").Save(layout).Error
+}
+
+func (userRepo *userRepository) DeleteDashboardLayout(userID int, houseID int) error {
+	return userRepo.db.Table("Dashboard_layout").Where("User_id = ? and House_id = ?", userID, houseID).Delete(&entity.DashboardLayout{}).Error
+}
+
 func (userRepo *userRepository) CreateNotification(userID int, houseID int, notification *entity.Notification) error {
 	// transaction
 	tx := userRepo.db.Begin()
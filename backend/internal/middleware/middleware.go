@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
 	"net/http"
+	"strconv"
+	"strings"
 
 	token "go-jwt/internal/middleware/token"
 
@@ -20,6 +24,97 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// HeaderTokenMiddleware refuses the token in ?token=, it goes before JwtAuthMiddleware on the routes of private
+// data. A token in the url ends up in the logs of the proxies and in the history of the browser.
+func HeaderTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("token") != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "send the token in the Authorization header"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminMiddleware only lets through the users listed in ADMIN_USER_IDS, it goes after JwtAuthMiddleware
+func AdminMiddleware() gin.HandlerFunc {
+	admins := config.IntList("ADMIN_USER_IDS")
+	return func(c *gin.Context) {
+		userID, err := token.ExtractTokenUserID(c)
+		if err != nil {
+			c.String(http.StatusUnauthorized, "Unauthorized")
+			c.Abort()
+			return
+		}
+		for _, admin := range admins {
+			if admin == userID {
+				c.Next()
+				return
+			}
+		}
+		c.String(http.StatusForbidden, "Forbidden")
+		c.Abort()
+	}
+}
+
+// HouseMemberMiddleware only lets the users of the house :id through, the members of a house are in the Own
+// table. It goes after JwtAuthMiddleware on the /houses/:id routes.
+func HouseMemberMiddleware(isMember func(userID int, houseID int) (bool, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := token.ExtractTokenUserID(c)
+		if err != nil {
+			c.String(http.StatusUnauthorized, "Unauthorized")
+			c.Abort()
+			return
+		}
+		houseID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid house id in the url"})
+			c.Abort()
+			return
+		}
+		member, err := isMember(userID, houseID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !member {
+			c.String(http.StatusForbidden, "Forbidden")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// DeviceAuthMiddleware finds the device of the key in the "X-Device-Key" header or in "Authorization: Device <key>",
+// and puts it in the context under "device"
+func DeviceAuthMiddleware(authenticate func(key string) (*entity.Device, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Device-Key")
+		if key == "" {
+			if scheme, value, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "Device") {
+				key = strings.TrimSpace(value)
+			}
+		}
+		if key == "" {
+			c.String(http.StatusUnauthorized, "Unauthorized")
+			c.Abort()
+			return
+		}
+		device, err := authenticate(key)
+		if err != nil {
+			c.String(http.StatusUnauthorized, "Unauthorized")
+			c.Abort()
+			return
+		}
+		c.Set("device", device)
+		c.Next()
+	}
+}
+
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
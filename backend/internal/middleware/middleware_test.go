@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	token "go-jwt/internal/middleware/token"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHouseMemberMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	owns := func(userID int, houseID int) (bool, error) {
+		if houseID == 99 {
+			return false, errors.New("database down")
+		}
+		return userID == 1 && houseID == 2, nil
+	}
+	router := gin.New()
+	router.GET("/houses/:id/cameras", JwtAuthMiddleware(), HouseMemberMiddleware(owns), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	member, err := token.GenerateToken("alice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := token.GenerateToken("bob", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"member", "/houses/2/cameras", member, http.StatusOK},
+		{"other house", "/houses/5/cameras", member, http.StatusForbidden},
+		{"stranger", "/houses/2/cameras", stranger, http.StatusForbidden},
+		{"no token", "/houses/2/cameras", "", http.StatusUnauthorized},
+		{"bad house id", "/houses/x/cameras", member, http.StatusBadRequest},
+		{"lookup fails", "/houses/99/cameras", member, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderTokenMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/houses/:id/snapshots", HeaderTokenMiddleware(), JwtAuthMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	member, err := token.GenerateToken("alice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/houses/2/snapshots?token="+member, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("token in the url: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/houses/2/snapshots", nil)
+	req.Header.Set("Authorization", "Bearer "+member)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("token in the header: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
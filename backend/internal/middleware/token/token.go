@@ -10,7 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func GenerateToken(username string) (string, error) {
+func GenerateToken(username string, userID int) (string, error) {
 
 	token_lifespan, err := strconv.Atoi("1")
 
@@ -21,6 +21,7 @@ func GenerateToken(username string) (string, error) {
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["user_id"] = username
+	claims["uid"] = userID
 	claims["exp"] = time.Now().Add(24 * time.Hour * time.Duration(token_lifespan)).Unix()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
@@ -85,3 +86,29 @@ func ExtractTokenID(c *gin.Context) (string, error) {
 	// Return an error if the token is not valid
 	return "", fmt.Errorf("invalid token")
 }
+
+// ExtractTokenUserID returns the User_id of the user the token was given to
+func ExtractTokenUserID(c *gin.Context) (int, error) {
+	tokenString := ExtractToken(c)
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte("yoursecretstring"), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		// numbers of the claims are decoded as float64
+		userID, ok := claims["uid"].(float64)
+		if !ok {
+			return 0, fmt.Errorf("uid not found in token claims")
+		}
+		return int(userID), nil
+	}
+
+	return 0, fmt.Errorf("invalid token")
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Policy is how many attempts a key may make in a sliding window, and how many consecutive
+// failures lock it out for how long
+type Policy struct {
+	Limit     int
+	Window    time.Duration
+	Threshold int
+	LockFor   time.Duration
+}
+
+// Lockout is a key that failed too many times in a row
+type Lockout struct {
+	Key         string    `json:"key"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// Limiter counts the attempts of every key in a sliding window, in the memory of this instance, and keeps the
+// failures and the lockouts in the store, so they outlive a restart and are shared by the instances.
+type Limiter struct {
+	mu       sync.Mutex
+	policy   Policy
+	store    Store
+	attempts map[string][]time.Time
+}
+
+func New(policy Policy, store Store) *Limiter {
+	return &Limiter{
+		policy:   policy,
+		store:    store,
+		attempts: make(map[string][]time.Time),
+	}
+}
+
+// Allow records an attempt of every key. It returns how long to wait when one of them is
+// locked out or over the limit, in which case no attempt is recorded. The lockouts are not
+// enforced when the store cannot be read.
+func (l *Limiter) Allow(now time.Time, keys ...string) (time.Duration, bool) {
+	var wait time.Duration
+	lockedUntil, err := l.store.LockedUntil(now, keys)
+	if err != nil {
+		fmt.Println("get lockouts failed:", err.Error())
+	} else if now.Before(lockedUntil) {
+		wait = lockedUntil.Sub(now)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		l.attempts[key] = l.recent(l.attempts[key], now)
+		if attempts := l.attempts[key]; len(attempts) >= l.policy.Limit {
+			wait = maxDuration(wait, attempts[0].Add(l.policy.Window).Sub(now))
+		}
+	}
+	if wait > 0 {
+		return wait, false
+	}
+
+	for _, key := range keys {
+		l.attempts[key] = append(l.attempts[key], now)
+	}
+	return 0, true
+}
+
+// Failure counts a failure of every key and returns the keys it locked out
+func (l *Limiter) Failure(now time.Time, keys ...string) []string {
+	var locked []string
+	for _, key := range keys {
+		ok, err := l.store.Fail(key, now, l.policy.Threshold, now.Add(l.policy.LockFor))
+		if err != nil {
+			fmt.Println("count failure failed:", err.Error())
+			continue
+		}
+		if ok {
+			locked = append(locked, key)
+		}
+	}
+	return locked
+}
+
+// Success resets the consecutive failures of every key
+func (l *Limiter) Success(keys ...string) {
+	if err := l.store.Reset(keys); err != nil {
+		fmt.Println("reset failures failed:", err.Error())
+	}
+}
+
+// Clear forgets the key, it returns false when the key was not known
+func (l *Limiter) Clear(key string) (bool, error) {
+	l.mu.Lock()
+	_, ok := l.attempts[key]
+	delete(l.attempts, key)
+	l.mu.Unlock()
+
+	cleared, err := l.store.Clear(key)
+	if err != nil {
+		return false, err
+	}
+	return ok || cleared, nil
+}
+
+// Prune forgets the keys with nothing left to remember, so the map and the store do not grow forever
+func (l *Limiter) Prune(now time.Time) {
+	l.mu.Lock()
+	for key, attempts := range l.attempts {
+		if l.attempts[key] = l.recent(attempts, now); len(l.attempts[key]) == 0 {
+			delete(l.attempts, key)
+		}
+	}
+	l.mu.Unlock()
+
+	if err := l.store.Prune(now); err != nil {
+		fmt.Println("prune lockouts failed:", err.Error())
+	}
+}
+
+// recent drops the attempts that left the window
+func (l *Limiter) recent(attempts []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(attempts) && !attempts[i].After(now.Add(-l.policy.Window)) {
+		i++
+	}
+	return attempts[i:]
+}
+
+func maxDuration(a time.Duration, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
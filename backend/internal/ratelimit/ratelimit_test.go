@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterRate(t *testing.T) {
+	limiter := New(Policy{Limit: 2, Window: time.Minute, Threshold: 5, LockFor: time.Minute}, NewMemoryStore())
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := limiter.Allow(now, "ip:1"); !ok {
+			t.Fatalf("attempt %d refused", i+1)
+		}
+	}
+	wait, ok := limiter.Allow(now.Add(10*time.Second), "ip:1")
+	if ok || wait != 50*time.Second {
+		t.Fatalf("third attempt = %v, %v, want refused for 50s", wait, ok)
+	}
+	if _, ok := limiter.Allow(now.Add(time.Minute), "ip:1"); !ok {
+		t.Fatal("attempt after the window refused")
+	}
+}
+
+// two instances share the lockouts of their store, not the rate of their attempts
+func TestLimiterSharedLockout(t *testing.T) {
+	store := NewMemoryStore()
+	policy := Policy{Limit: 100, Window: time.Minute, Threshold: 3, LockFor: 5 * time.Minute}
+	a, b := New(policy, store), New(policy, store)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if locked := a.Failure(now, "camera:1"); len(locked) != 0 {
+		t.Fatalf("locked after one failure: %v", locked)
+	}
+	a.Success("camera:1")
+	a.Failure(now, "camera:1")
+	b.Failure(now, "camera:1")
+	if locked := a.Failure(now, "camera:1", "ip:1"); len(locked) != 1 || locked[0] != "camera:1" {
+		t.Fatalf("locked = %v, want [camera:1]", locked)
+	}
+
+	wait, ok := b.Allow(now.Add(time.Minute), "camera:1")
+	if ok || wait != 4*time.Minute {
+		t.Fatalf("allow on the other instance = %v, %v, want refused for 4m", wait, ok)
+	}
+	if _, ok := b.Allow(now.Add(time.Minute), "camera:2"); !ok {
+		t.Fatal("another camera refused")
+	}
+	if _, ok := a.Allow(now.Add(5*time.Minute), "camera:1"); !ok {
+		t.Fatal("attempt after the lockout refused")
+	}
+
+	lockouts, err := store.Lockouts(now)
+	if err != nil || len(lockouts) != 1 || lockouts[0].Key != "camera:1" {
+		t.Fatalf("lockouts = %v, %v", lockouts, err)
+	}
+	if cleared, err := b.Clear("camera:1"); err != nil || !cleared {
+		t.Fatalf("clear = %v, %v", cleared, err)
+	}
+	if _, ok := a.Allow(now.Add(time.Minute), "camera:1"); !ok {
+		t.Fatal("attempt after clear refused")
+	}
+}
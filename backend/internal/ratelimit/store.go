@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store keeps the consecutive failures and the lockouts of the keys. Several limiters may share a store when
+// their keys do not collide.
+type Store interface {
+	// Fail counts a failure of the key, and locks it until lockUntil when it reaches threshold and is not
+	// locked already. It returns true when it locked the key.
+	Fail(key string, now time.Time, threshold int, lockUntil time.Time) (bool, error)
+	// LockedUntil returns the end of the longest lockout of the keys at now, the zero time when none is locked
+	LockedUntil(now time.Time, keys []string) (time.Time, error)
+	Reset(keys []string) error
+	// Lockouts returns the keys locked out at now, sorted by key
+	Lockouts(now time.Time) ([]Lockout, error)
+	Clear(key string) (bool, error)
+	Prune(now time.Time) error
+}
+
+// MemoryStore is a Store of a single instance, it forgets everything on restart
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	failures    int
+	lockedUntil time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*entry),
+	}
+}
+
+func (m *MemoryStore) Fail(key string, now time.Time, threshold int, lockUntil time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		e = &entry{}
+		m.entries[key] = e
+	}
+	e.failures++
+	if e.failures >= threshold && !now.Before(e.lockedUntil) {
+		e.lockedUntil = lockUntil
+		e.failures = 0
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) LockedUntil(now time.Time, keys []string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var until time.Time
+	for _, key := range keys {
+		if e, ok := m.entries[key]; ok && now.Before(e.lockedUntil) && e.lockedUntil.After(until) {
+			until = e.lockedUntil
+		}
+	}
+	return until, nil
+}
+
+func (m *MemoryStore) Reset(keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := m.entries[key]; ok {
+			e.failures = 0
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Lockouts(now time.Time) ([]Lockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lockouts := []Lockout{}
+	for key, e := range m.entries {
+		if now.Before(e.lockedUntil) {
+			lockouts = append(lockouts, Lockout{Key: key, LockedUntil: e.lockedUntil})
+		}
+	}
+	sort.Slice(lockouts, func(i, j int) bool { return lockouts[i].Key < lockouts[j].Key })
+	return lockouts, nil
+}
+
+func (m *MemoryStore) Clear(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.entries[key]
+	delete(m.entries, key)
+	return ok, nil
+}
+
+func (m *MemoryStore) Prune(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, e := range m.entries {
+		if e.failures == 0 && !now.Before(e.lockedUntil) {
+			delete(m.entries, key)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,14 @@
 package request
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	entity "go-jwt/internal/entity"
+	"go-jwt/internal/imageprep"
+	"io"
+	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -12,47 +19,105 @@ func NewDeviceRequest() DeviceRequest {
 }
 
 type DeviceRequest interface {
-	GetDataFromDeviceRequest(c *gin.Context) (int, int, string, float64, bool, error)
+	GetDataFromDeviceRequest(c *gin.Context) (float64, bool)
+	GetImage(ctx *gin.Context) ([]byte, string, string, error)
+	GetPersonID(ctx *gin.Context) (*int, error)
+	GetDevice(ctx *gin.Context) (*entity.Device, error)
+	GetReadings(ctx *gin.Context) ([]entity.Reading, error)
 }
 
 type deviceRequest struct {
 }
 
-//api route: /devices/update?house_id=1&device_id=2&device_type=Door&data=30&state=1
+//api route: /devices/update?data=30&state=1, the house and the device come from the key of the device
 
-func (r *deviceRequest) GetDataFromDeviceRequest(ctx *gin.Context) (int, int, string, float64, bool, error) {
+func (r *deviceRequest) GetDataFromDeviceRequest(ctx *gin.Context) (float64, bool) {
 	// read the request url to extract the query parameters
-	HouseID, _ := ctx.GetQuery("house_id")
-	DeviceID, _ := ctx.GetQuery("device_id")
-	deviceType, _ := ctx.GetQuery("device_type")
 	Data, _ := ctx.GetQuery("data")
 	State, _ := ctx.GetQuery("state")
 
 	// convert the query parameters to the desired data type
-	houseID, _ := strconv.Atoi(HouseID)
-	deviceID, _ := strconv.Atoi(DeviceID)
 	data, _ := strconv.ParseFloat(Data, 64)
 	state, _ := strconv.ParseBool(State)
 
-	if data < 0 {
-		return 0, 0, "", 0, false, errors.New("data cannot be negative")
-	}
+	// the data is checked against the type of the device by the usecase
+	return data, state
+}
 
-	if deviceType == "Door" {
+// telemetry larger than this is refused before it is decoded
+const maxTelemetryBytes = 4 << 20
 
-	} else if deviceType == "Temperature" {
-		if data > 100 {
-			return 0, 0, "", 0, false, errors.New("fire detected! temperature cannot be more than 100")
-		}
-	} else if deviceType == "Humidity" {
+// GetReadings reads the readings of POST /devices/telemetry, a JSON array or one JSON reading per line
+func (r *deviceRequest) GetReadings(ctx *gin.Context) ([]entity.Reading, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxTelemetryBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", entity.ErrTelemetryTooLarge, maxTelemetryBytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %s", err.Error())
+	}
 
-	} else if deviceType == "Fan" {
-		if data > 100 {
-			return 0, 0, "", 0, false, errors.New("fan speed cannot be more than 100")
+	var readings []entity.Reading
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &readings); err != nil {
+			return nil, fmt.Errorf("%w: failed to parse JSON", entity.ErrInvalidTelemetry)
 		}
-		// } else if deviceType == "Light" {
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		for decoder.More() {
+			var reading entity.Reading
+			if err := decoder.Decode(&reading); err != nil {
+				return nil, fmt.Errorf("%w: failed to parse reading %d", entity.ErrInvalidTelemetry, len(readings)+1)
+			}
+			readings = append(readings, reading)
+		}
+	}
+	return readings, nil
+}
+
+// GetImage reads the "img" file of the request, with its file name and the Content-Type the client gave it
+func (r *deviceRequest) GetImage(ctx *gin.Context) ([]byte, string, string, error) {
+	file, err := ctx.FormFile("img")
+	if err != nil {
+		return nil, "", "", entity.ErrImageRequired
+	}
+	// refuse a big file before reading it
+	if file.Size > imageprep.MaxBytes {
+		return nil, "", "", fmt.Errorf("%w: %d bytes, the limit is %d", entity.ErrImageTooLarge, file.Size, imageprep.MaxBytes)
+	}
+
+	fileHandle, err := file.Open()
+	if err != nil {
+		return nil, "", "", errors.New("failed to open file")
+	}
+	defer fileHandle.Close()
+
+	image, err := io.ReadAll(fileHandle)
+	if err != nil {
+		return nil, "", "", errors.New("failed to read image file")
+	}
+	return image, file.Filename, file.Header.Get("Content-Type"), nil
+}
+
+// GetPersonID reads the optional "person_id" form field, nil when it is not set
+func (r *deviceRequest) GetPersonID(ctx *gin.Context) (*int, error) {
+	value, ok := ctx.GetPostForm("person_id")
+	if !ok || value == "" {
+		return nil, nil
+	}
+	personID, err := strconv.Atoi(value)
+	if err != nil || personID <= 0 {
+		return nil, errors.New("invalid 'person_id'")
+	}
+	return &personID, nil
+}
 
-		// }
+// GetDevice returns the device that sent the request, set by DeviceAuthMiddleware
+func (r *deviceRequest) GetDevice(ctx *gin.Context) (*entity.Device, error) {
+	device, ok := ctx.Get("device")
+	if !ok {
+		return nil, entity.ErrInvalidDeviceKey
 	}
-	return houseID, deviceID, deviceType, data, state, nil
+	return device.(*entity.Device), nil
 }
@@ -0,0 +1,153 @@
+package request
+
+import (
+	"errors"
+	"go-jwt/internal/entity"
+	token "go-jwt/internal/middleware/token"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewHouseRequest() HouseRequest {
+	return &houseRequest{}
+}
+
+// HouseRequest reads the requests of the /houses/:id/... routes
+type HouseRequest interface {
+	GetHouseID(ctx *gin.Context) (int, error)
+	GetIntParam(ctx *gin.Context, name string) (int, error)
+	GetTimeRange(ctx *gin.Context, defaultSpan time.Duration) (time.Time, time.Time, error)
+	GetRoomFilter(ctx *gin.Context) (*int, error)
+	GetActivityFilter(ctx *gin.Context) (entity.ActivityFilter, error)
+	GetActor(ctx *gin.Context) (entity.Actor, error)
+}
+
+type houseRequest struct {
+}
+
+// /houses/1/schedules
+func (r *houseRequest) GetHouseID(ctx *gin.Context) (int, error) {
+	return r.GetIntParam(ctx, "id")
+}
+
+// /houses/1/schedules/2 with name "schedule_id"
+func (r *houseRequest) GetIntParam(ctx *gin.Context, name string) (int, error) {
+	value, err := strconv.Atoi(ctx.Param(name))
+	if err != nil || value <= 0 {
+		return 0, errors.New("invalid '" + name + "' in the url")
+	}
+	return value, nil
+}
+
+// ?from=2024-05-01T00:00:00Z&to=2024-05-02T00:00:00Z, to is now and from is defaultSpan before to when they are missing
+func (r *houseRequest) GetTimeRange(ctx *gin.Context, defaultSpan time.Duration) (time.Time, time.Time, error) {
+	to := time.Now()
+	if value := ctx.Query("to"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid 'to', it must be an RFC 3339 time")
+		}
+		to = t
+	}
+	from := to.Add(-defaultSpan)
+	if value := ctx.Query("from"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid 'from', it must be an RFC 3339 time")
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("'from' must be before 'to'")
+	}
+	return from, to, nil
+}
+
+// GetActor is the user of the token acting through the api
+func (r *houseRequest) GetActor(ctx *gin.Context) (entity.Actor, error) {
+	return tokenActor(ctx, entity.SourceAPI)
+}
+
+// tokenActor is the user of the token. The tokens signed before the uid claim have no user id, their actions
+// are refused rather than logged without anyone.
+func tokenActor(ctx *gin.Context, source string) (entity.Actor, error) {
+	userID, err := token.ExtractTokenUserID(ctx)
+	if err != nil {
+		return entity.Actor{}, entity.ErrTokenWithoutUser
+	}
+	return entity.UserActor(userID, source), nil
+}
+
+// ?room_id=2, nil when the list is not filtered by room
+func (r *houseRequest) GetRoomFilter(ctx *gin.Context) (*int, error) {
+	return roomFilter(ctx)
+}
+
+func roomFilter(ctx *gin.Context) (*int, error) {
+	value, ok := ctx.GetQuery("room_id")
+	if !ok {
+		return nil, nil
+	}
+	roomID, err := strconv.Atoi(value)
+	if err != nil || roomID <= 0 {
+		return nil, errors.New("invalid 'room_id' in the url")
+	}
+	return &roomID, nil
+}
+
+// ?device_id=3&room_id=2&device=Camera&from=2024-05-01T00:00:00Z&to=2024-05-02T00:00:00Z&q=door&cursor=...&limit=50
+// and &actor=user&actor_id=4&source=app&event=command.acknowledged
+func (r *houseRequest) GetActivityFilter(ctx *gin.Context) (entity.ActivityFilter, error) {
+	filter := entity.ActivityFilter{
+		Device: ctx.Query("device"),
+		Search: ctx.Query("q"),
+		Cursor: ctx.Query("cursor"),
+		Actor:  ctx.Query("actor"),
+		Source: ctx.Query("source"),
+		Event:  ctx.Query("event"),
+	}
+	if len(filter.Search) > 100 {
+		return filter, errors.New("'q' must be at most 100 characters")
+	}
+
+	if value, ok := ctx.GetQuery("device_id"); ok {
+		deviceID, err := strconv.Atoi(value)
+		if err != nil || deviceID <= 0 {
+			return filter, errors.New("invalid 'device_id' in the url")
+		}
+		filter.Device_id = &deviceID
+	}
+	if value, ok := ctx.GetQuery("actor_id"); ok {
+		actorID, err := strconv.Atoi(value)
+		if err != nil || actorID <= 0 || filter.Actor == "" {
+			return filter, errors.New("invalid 'actor_id' in the url, it goes with 'actor'")
+		}
+		filter.Actor_id = &actorID
+	}
+	roomID, err := roomFilter(ctx)
+	if err != nil {
+		return filter, err
+	}
+	filter.Room_id = roomID
+
+	for name, field := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := ctx.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, errors.New("invalid '" + name + "', it must be an RFC 3339 time")
+			}
+			*field = &t
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, errors.New("'from' must be before 'to'")
+	}
+
+	filter.Limit, err = strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || filter.Limit <= 0 || filter.Limit > 200 {
+		return filter, errors.New("invalid 'limit' value. Must be between 1 and 200")
+	}
+	return filter, nil
+}
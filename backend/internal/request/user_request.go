@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"go-jwt/internal/entity"
+	token "go-jwt/internal/middleware/token"
 	"io"
 	"net/http"
 	"strconv"
@@ -24,7 +25,12 @@ type UserRequest interface {
 	GetUsername() string
 	GetPassword() string
 	GetUserIDFromURL(ctx *gin.Context) int
+	GetUserIDFromToken(ctx *gin.Context) (int, error)
+	GetActor(ctx *gin.Context) (entity.Actor, error)
 	GetHouseIDFromURL(ctx *gin.Context) int
+	GetRoomIDFromURL(ctx *gin.Context) (*int, error)
+	GetNotificationID(ctx *gin.Context) (int, error)
+	GetNotificationFilter(ctx *gin.Context) (entity.NotificationFilter, error)
 	GetHouseSettingNameFromURL(ctx *gin.Context) string
 	GetLightLevel(ctx *gin.Context) (float64, error)
 	GetFanSpeed(ctx *gin.Context) (float64, error)
@@ -88,6 +94,16 @@ func (r *userRequest) GetUserIDFromURL(ctx *gin.Context) int {
 	return user_id
 }
 
+// GetUserIDFromToken returns the user of the token, for the /users/me/... routes
+func (r *userRequest) GetUserIDFromToken(ctx *gin.Context) (int, error) {
+	return token.ExtractTokenUserID(ctx)
+}
+
+// GetActor is the user of the token acting through the app
+func (r *userRequest) GetActor(ctx *gin.Context) (entity.Actor, error) {
+	return tokenActor(ctx, entity.SourceApp)
+}
+
 // /users/getHouseSettingByHouseID?house_id=1
 func (r *userRequest) GetHouseIDFromURL(ctx *gin.Context) int {
 	houseID, _ := ctx.GetQuery("house_id")
@@ -95,6 +111,40 @@ func (r *userRequest) GetHouseIDFromURL(ctx *gin.Context) int {
 	return house_id
 }
 
+// /users/getActivityLog?house_id=1&room_id=2, nil without room_id
+func (r *userRequest) GetRoomIDFromURL(ctx *gin.Context) (*int, error) {
+	return roomFilter(ctx)
+}
+
+// /users/me/notifications/5/read
+func (r *userRequest) GetNotificationID(ctx *gin.Context) (int, error) {
+	notificationID, err := strconv.Atoi(ctx.Param("notification_id"))
+	if err != nil {
+		return 0, errors.New("invalid 'notification_id' in the url")
+	}
+	return notificationID, nil
+}
+
+// /users/me/notifications?house_id=1&unread=true&archived=false, every house without house_id
+func (r *userRequest) GetNotificationFilter(ctx *gin.Context) (entity.NotificationFilter, error) {
+	filter := entity.NotificationFilter{}
+	if value, ok := ctx.GetQuery("house_id"); ok {
+		houseID, err := strconv.Atoi(value)
+		if err != nil || houseID <= 0 {
+			return filter, errors.New("invalid 'house_id' in the url")
+		}
+		filter.House_id = &houseID
+	}
+	var err error
+	if filter.Unread, err = strconv.ParseBool(ctx.DefaultQuery("unread", "false")); err != nil {
+		return filter, errors.New("invalid 'unread' in the url")
+	}
+	if filter.Archived, err = strconv.ParseBool(ctx.DefaultQuery("archived", "false")); err != nil {
+		return filter, errors.New("invalid 'archived' in the url")
+	}
+	return filter, nil
+}
+
 func (r *userRequest) GetHouseSettingNameFromURL(ctx *gin.Context) string {
 	return ctx.Query("name")
 }
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	entity "go-jwt/internal/entity"
+	"time"
+)
+
+// Runner runs the schedules that are due at now, it is implemented by the schedule usecase
+type Runner interface {
+	RunDueSchedules(now time.Time)
+}
+
+// Start runs the due schedules right away, which catches up the runs missed while the server was down,
+// then every interval until ctx is done.
+func Start(ctx context.Context, runner Runner, interval time.Duration) {
+	Every(ctx, interval, runner.RunDueSchedules)
+}
+
+// Every calls run in a goroutine right away and then every interval until ctx is done.
+// A run that takes longer than interval delays the next one, runs never overlap.
+func Every(ctx context.Context, interval time.Duration, run func(now time.Time)) {
+	go func() {
+		run(time.Now())
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				run(now)
+			}
+		}
+	}()
+}
+
+// NextRun returns the first time strictly after t the schedule has to run.
+// The zero time means the schedule will never run again.
+func NextRun(schedule *entity.Schedule, t time.Time, house *entity.House) (time.Time, error) {
+	switch schedule.Kind {
+	case entity.ScheduleKindCron:
+		cron, err := ParseCron(schedule.Cron_expr)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %s", entity.ErrInvalidSchedule, err.Error())
+		}
+		return cron.Next(t), nil
+
+	case entity.ScheduleKindOnce:
+		if schedule.Run_at == nil {
+			return time.Time{}, fmt.Errorf("%w: run_at is required", entity.ErrInvalidSchedule)
+		}
+		if schedule.Run_at.After(t) {
+			return *schedule.Run_at, nil
+		}
+		return time.Time{}, nil
+
+	case entity.ScheduleKindSun:
+		if schedule.Sun_event != entity.SunEventSunrise && schedule.Sun_event != entity.SunEventSunset {
+			return time.Time{}, fmt.Errorf("%w: sun_event must be sunrise or sunset", entity.ErrInvalidSchedule)
+		}
+		if house == nil || house.Latitude == nil || house.Longitude == nil {
+			return time.Time{}, entity.ErrHouseLocationNotSet
+		}
+		offset := time.Duration(schedule.Offset_minutes) * time.Minute
+		// look a bit more than a day ahead for the offset, and skip the days without sunrise or sunset
+		for day := -1; day <= 366; day++ {
+			sun, ok := SunTime(t.AddDate(0, 0, day), *house.Latitude, *house.Longitude, schedule.Sun_event == entity.SunEventSunrise)
+			if ok && sun.Add(offset).After(t) {
+				return sun.Add(offset), nil
+			}
+		}
+		return time.Time{}, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: unknown kind %q", entity.ErrInvalidSchedule, schedule.Kind)
+}
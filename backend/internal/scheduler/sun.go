@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"math"
+	"time"
+)
+
+// official zenith of the sun for sunrise and sunset, accounting for refraction
+const sunZenith = 90.833
+
+// SunTime returns the sunrise (rising = true) or sunset of the calendar day of date at the given
+// coordinates, in the location of date. ok is false when the sun does not rise or set that day
+// (polar day or night). The algorithm is the one of the Almanac for Computers (1990), which is
+// accurate to about a minute.
+func SunTime(date time.Time, latitude float64, longitude float64, rising bool) (time.Time, bool) {
+	loc := date.Location()
+	year, month, day := date.Date()
+	dayOfYear := float64(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).YearDay())
+
+	lngHour := longitude / 15
+	var t float64
+	if rising {
+		t = dayOfYear + (6-lngHour)/24
+	} else {
+		t = dayOfYear + (18-lngHour)/24
+	}
+
+	// sun's mean anomaly and true longitude
+	m := 0.9856*t - 3.289
+	l := normalizeDegrees(m + 1.916*sinDeg(m) + 0.020*sinDeg(2*m) + 282.634)
+
+	// right ascension, in the same quadrant as l, in hours
+	ra := normalizeDegrees(radToDeg(math.Atan(0.91764 * tanDeg(l))))
+	ra += math.Floor(l/90)*90 - math.Floor(ra/90)*90
+	ra /= 15
+
+	// declination and local hour angle
+	sinDec := 0.39782 * sinDeg(l)
+	cosDec := math.Cos(math.Asin(sinDec))
+	cosH := (cosDeg(sunZenith) - sinDec*sinDeg(latitude)) / (cosDec * cosDeg(latitude))
+	if cosH > 1 || cosH < -1 {
+		return time.Time{}, false
+	}
+	var h float64
+	if rising {
+		h = 360 - radToDeg(math.Acos(cosH))
+	} else {
+		h = radToDeg(math.Acos(cosH))
+	}
+	h /= 15
+
+	localMeanTime := h + ra - 0.06571*t - 6.622
+	ut := math.Mod(localMeanTime-lngHour+48, 24)
+
+	result := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Add(time.Duration(ut * float64(time.Hour))).In(loc)
+
+	// the UTC day may differ from the local one, keep the event on the requested calendar day
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if result.Before(midnight) {
+		result = result.Add(24 * time.Hour)
+	} else if !result.Before(midnight.AddDate(0, 0, 1)) {
+		result = result.Add(-24 * time.Hour)
+	}
+	return result, true
+}
+
+func normalizeDegrees(d float64) float64 {
+	d = math.Mod(d, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d
+}
+
+func radToDeg(r float64) float64 { return r * 180 / math.Pi }
+
+func sinDeg(d float64) float64 { return math.Sin(d * math.Pi / 180) }
+
+func cosDeg(d float64) float64 { return math.Cos(d * math.Pi / 180) }
+
+func tanDeg(d float64) float64 { return math.Tan(d * math.Pi / 180) }
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"go-jwt/internal/entity"
+	"testing"
+	"time"
+)
+
+func TestSunTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		date      time.Time
+		lat, lng  float64
+		rising    bool
+		want      time.Time
+		wantPolar bool
+	}{
+		// the almanac times, within a few minutes
+		{"London sunrise", time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 51.5074, -0.1278, true,
+			time.Date(2024, 6, 21, 3, 43, 0, 0, time.UTC), false},
+		{"London sunset", time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 51.5074, -0.1278, false,
+			time.Date(2024, 6, 21, 20, 21, 0, 0, time.UTC), false},
+		{"Ho Chi Minh City sunrise", time.Date(2024, 1, 1, 12, 0, 0, 0, time.FixedZone("ICT", 7*3600)), 10.8231, 106.6297, true,
+			time.Date(2024, 1, 1, 6, 11, 0, 0, time.FixedZone("ICT", 7*3600)), false},
+		{"Tromso polar night", time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), 69.6492, 18.9553, true,
+			time.Time{}, true},
+		{"Tromso midnight sun", time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 69.6492, 18.9553, false,
+			time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SunTime(tt.date, tt.lat, tt.lng, tt.rising)
+			if ok == tt.wantPolar {
+				t.Fatalf("ok = %v, want %v", ok, !tt.wantPolar)
+			}
+			if tt.wantPolar {
+				return
+			}
+			if diff := got.Sub(tt.want); diff < -3*time.Minute || diff > 3*time.Minute {
+				t.Errorf("SunTime = %v, want %v", got, tt.want)
+			}
+			if y, m, d := got.Date(); y != tt.date.Year() || m != tt.date.Month() || d != tt.date.Day() {
+				t.Errorf("SunTime = %v, not on the day of %v", got, tt.date)
+			}
+		})
+	}
+}
+
+func TestNextRunSun(t *testing.T) {
+	lat, lng := 51.5074, -0.1278
+	house := &entity.House{Latitude: &lat, Longitude: &lng}
+	schedule := &entity.Schedule{Kind: entity.ScheduleKindSun, Sun_event: entity.SunEventSunset, Offset_minutes: -30}
+
+	// after the sunset of the day minus the offset, the next run is the next day
+	from := time.Date(2024, 6, 21, 20, 0, 0, 0, time.UTC)
+	got, err := NextRun(schedule, from, house)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y, m, d := got.Date(); y != 2024 || m != 6 || d != 22 || got.Hour() != 19 {
+		t.Errorf("NextRun = %v, want the evening of 2024-06-22", got)
+	}
+
+	if _, err := NextRun(schedule, from, &entity.House{}); err != entity.ErrHouseLocationNotSet {
+		t.Errorf("NextRun without location = %v, want %v", err, entity.ErrHouseLocationNotSet)
+	}
+	schedule.Sun_event = "noon"
+	if _, err := NextRun(schedule, from, house); err == nil {
+		t.Error("NextRun with an unknown sun event did not fail")
+	}
+}
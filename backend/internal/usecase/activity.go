@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"fmt"
+	entity "go-jwt/internal/entity"
+	"time"
+)
+
+// GetActivityLog returns a page of the activity log of the house, the newest entries first.
+// One more entry than the limit is read to know whether there is a next page.
+func (s *userUsecase) GetActivityLog(houseID int, filter entity.ActivityFilter) (*entity.ActivityPage, error) {
+	after, err := decodeActivityCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	activityLogs, err := s.userRepo.GetActivityLog(houseID, filter, after, filter.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entity.ActivityPage{Items: activityLogs}
+	if len(activityLogs) > filter.Limit {
+		page.Items = activityLogs[:filter.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.Next_cursor = encodeActivityCursor(entity.ActivityCursor{Time: last.Time, ID: last.ID})
+	}
+	if page.Items == nil {
+		page.Items = []entity.ActivityLog{}
+	}
+	return page, nil
+}
+
+// the cursor is opaque to the clients, it is the time and the id of the last entry of the page
+func encodeActivityCursor(cursor entity.ActivityCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", cursor.Time.UnixNano(), cursor.ID)))
+}
+
+func decodeActivityCursor(value string) (*entity.ActivityCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, entity.ErrInvalidCursor
+	}
+	var nanos int64
+	var id int
+	if n, err := fmt.Sscanf(string(raw), "%d.%d", &nanos, &id); err != nil || n != 2 {
+		return nil, entity.ErrInvalidCursor
+	}
+	return &entity.ActivityCursor{Time: time.Unix(0, nanos), ID: id}, nil
+}
@@ -0,0 +1,246 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// the chain is read by pages when it is verified
+	auditVerifyPage = 500
+	// an append that lost its seq to another process is tried again on the new last entry
+	auditRecordAttempts = 5
+)
+
+func NewAuditUsecase(auditRepo repository.AuditRepository) AuditUsecase {
+	return &auditUsecase{
+		auditRepo:  auditRepo,
+		signingKey: []byte(config.String("AUDIT_SIGNING_KEY", "")),
+	}
+}
+
+// AuditUsecase is the tamper-evident log of the door and security events. Every entry holds the hash of the
+// previous entry of the house, and the last hash of each house is signed in a checkpoint from time to time,
+// so an entry edited or deleted in the database is found by Verify.
+type AuditUsecase interface {
+	Record(houseID int, actor entity.Actor, deviceID *int, code string, description string, params map[string]interface{}) error
+	GetEntries(houseID int, afterSeq int64, limit int) ([]entity.AuditEntry, error)
+	GetCheckpoints(houseID int) ([]entity.AuditCheckpoint, error)
+	Verify(houseID int) (*entity.AuditVerification, error)
+	Checkpoint(now time.Time) error
+}
+
+type auditUsecase struct {
+	auditRepo  repository.AuditRepository
+	signingKey []byte
+	// appending reads the last entry, two appends of a house at once would take the same seq. The lock only
+	// covers this process, the unique index of the seq catches the others.
+	mu sync.Mutex
+}
+
+// Record appends the event to the audit log of the house
+func (s *auditUsecase) Record(houseID int, actor entity.Actor, deviceID *int, code string, description string, params map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < auditRecordAttempts; attempt++ {
+		var entry *entity.AuditEntry
+		if entry, err = s.nextEntry(houseID, actor, deviceID, code, description, params); err != nil {
+			return err
+		}
+		if err = s.auditRepo.CreateAuditEntry(entry); !errors.Is(err, entity.ErrAuditSeqTaken) {
+			return err
+		}
+	}
+	return err
+}
+
+// nextEntry is the entry that follows the last entry of the house, with its hash
+func (s *auditUsecase) nextEntry(houseID int, actor entity.Actor, deviceID *int, code string, description string, params map[string]interface{}) (*entity.AuditEntry, error) {
+	last, err := s.auditRepo.GetLastAuditEntry(houseID)
+	if err != nil {
+		return nil, err
+	}
+	entry := &entity.AuditEntry{
+		House_id:    houseID,
+		Seq:         1,
+		Time:        time.Now().UTC().Truncate(time.Microsecond),
+		Event_code:  code,
+		Actor:       actor,
+		Device_id:   deviceID,
+		Description: description,
+		Params:      params,
+		Prev_hash:   entity.AuditGenesisHash,
+	}
+	if last != nil {
+		entry.Seq = last.Seq + 1
+		entry.Prev_hash = last.Hash
+	}
+	if entry.Hash, err = auditHash(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *auditUsecase) GetEntries(houseID int, afterSeq int64, limit int) ([]entity.AuditEntry, error) {
+	return s.auditRepo.GetAuditEntries(houseID, afterSeq, limit)
+}
+
+func (s *auditUsecase) GetCheckpoints(houseID int) ([]entity.AuditCheckpoint, error) {
+	return s.auditRepo.GetCheckpoints(houseID)
+}
+
+// Verify walks the chain of the house from the first entry, then checks the checkpoints against it.
+// A broken chain is not an error, it is reported in the result.
+func (s *auditUsecase) Verify(houseID int) (*entity.AuditVerification, error) {
+	result := &entity.AuditVerification{
+		House_id:   houseID,
+		Valid:      true,
+		Last_hash:  entity.AuditGenesisHash,
+		Signed:     len(s.signingKey) > 0,
+		Checked_at: time.Now(),
+	}
+	broken := func(seq int64, format string, args ...interface{}) (*entity.AuditVerification, error) {
+		result.Valid = false
+		result.Broken_seq = &seq
+		result.Error = fmt.Sprintf(format, args...)
+		return result, nil
+	}
+
+	hashes := map[int64]string{}
+	var seq int64
+	for {
+		entries, err := s.auditRepo.GetAuditEntries(houseID, seq, auditVerifyPage)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entry := &entries[i]
+			if entry.Seq != seq+1 {
+				return broken(seq+1, "entry %d is missing", seq+1)
+			}
+			if entry.Prev_hash != result.Last_hash {
+				return broken(entry.Seq, "entry %d does not follow entry %d", entry.Seq, seq)
+			}
+			hash, err := auditHash(entry)
+			if err != nil {
+				return nil, err
+			}
+			if hash != entry.Hash {
+				return broken(entry.Seq, "entry %d was modified", entry.Seq)
+			}
+			seq = entry.Seq
+			result.Entries++
+			result.Last_hash = entry.Hash
+			hashes[entry.Seq] = entry.Hash
+		}
+		if len(entries) < auditVerifyPage {
+			break
+		}
+	}
+
+	checkpoints, err := s.auditRepo.GetCheckpoints(houseID)
+	if err != nil {
+		return nil, err
+	}
+	result.Checkpoints = len(checkpoints)
+	for _, checkpoint := range checkpoints {
+		if result.Signed && !hmac.Equal([]byte(checkpoint.Signature), []byte(s.sign(&checkpoint))) {
+			return broken(checkpoint.Seq, "checkpoint %d has a wrong signature", checkpoint.ID)
+		}
+		hash, ok := hashes[checkpoint.Seq]
+		if !ok {
+			// the checkpoint covers entries that are not in the chain anymore
+			return broken(checkpoint.Seq, "entry %d of checkpoint %d is missing", checkpoint.Seq, checkpoint.ID)
+		}
+		if hash != checkpoint.Hash {
+			return broken(checkpoint.Seq, "entry %d does not match checkpoint %d", checkpoint.Seq, checkpoint.ID)
+		}
+	}
+	return result, nil
+}
+
+// Checkpoint signs the last hash of every house that has new entries since its last checkpoint
+func (s *auditUsecase) Checkpoint(now time.Time) error {
+	if len(s.signingKey) == 0 {
+		return entity.ErrAuditSigningKeyMissing
+	}
+	houseIDs, err := s.auditRepo.GetAuditHouses()
+	if err != nil {
+		return err
+	}
+	for _, houseID := range houseIDs {
+		last, err := s.auditRepo.GetLastAuditEntry(houseID)
+		if err != nil || last == nil {
+			continue
+		}
+		previous, err := s.auditRepo.GetLastCheckpoint(houseID)
+		if err != nil {
+			fmt.Println("get audit checkpoint failed:", err.Error())
+			continue
+		}
+		if previous != nil && previous.Seq >= last.Seq {
+			continue
+		}
+		checkpoint := &entity.AuditCheckpoint{
+			House_id:   houseID,
+			Seq:        last.Seq,
+			Hash:       last.Hash,
+			Created_at: now.UTC().Truncate(time.Microsecond),
+		}
+		checkpoint.Signature = s.sign(checkpoint)
+		if err := s.auditRepo.CreateCheckpoint(checkpoint); err != nil {
+			fmt.Println("create audit checkpoint failed:", err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *auditUsecase) sign(checkpoint *entity.AuditCheckpoint) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(strconv.Itoa(checkpoint.House_id) + "|" + strconv.FormatInt(checkpoint.Seq, 10) + "|" +
+		checkpoint.Hash + "|" + checkpoint.Created_at.UTC().Format(time.RFC3339Nano)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditHash is the sha256 of the fields of the entry and of the previous hash. The time is in UTC to the
+// microsecond, what the database keeps of it.
+func auditHash(entry *entity.AuditEntry) (string, error) {
+	data, err := json.Marshal(struct {
+		House_id    int                    `json:"house_id"`
+		Seq         int64                  `json:"seq"`
+		Time        string                 `json:"time"`
+		Event_code  string                 `json:"event_code"`
+		Actor       entity.Actor           `json:"actor"`
+		Device_id   *int                   `json:"device_id"`
+		Description string                 `json:"description"`
+		Params      map[string]interface{} `json:"params"`
+		Prev_hash   string                 `json:"prev_hash"`
+	}{
+		House_id:    entry.House_id,
+		Seq:         entry.Seq,
+		Time:        entry.Time.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		Event_code:  entry.Event_code,
+		Actor:       entry.Actor,
+		Device_id:   entry.Device_id,
+		Description: entry.Description,
+		Params:      entry.Params,
+		Prev_hash:   entry.Prev_hash,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	entity "go-jwt/internal/entity"
+)
+
+// fakeAuditRepo keeps the entries the way the database gives them back: the params went through JSON and
+// the time comes in another zone
+type fakeAuditRepo struct {
+	entries     []entity.AuditEntry
+	checkpoints []entity.AuditCheckpoint
+	// before is appended by "another process" right before the next CreateAuditEntry
+	before *entity.AuditEntry
+}
+
+func (r *fakeAuditRepo) reload(entry entity.AuditEntry) entity.AuditEntry {
+	data, err := json.Marshal(entry.Params)
+	if err != nil {
+		panic(err)
+	}
+	entry.Params = nil
+	if err := json.Unmarshal(data, &entry.Params); err != nil {
+		panic(err)
+	}
+	entry.Time = entry.Time.In(time.FixedZone("UTC+7", 7*60*60))
+	return entry
+}
+
+func (r *fakeAuditRepo) CreateAuditEntry(entry *entity.AuditEntry) error {
+	if r.before != nil {
+		r.entries = append(r.entries, r.reload(*r.before))
+		r.before = nil
+	}
+	for _, stored := range r.entries {
+		if stored.House_id == entry.House_id && stored.Seq == entry.Seq {
+			return entity.ErrAuditSeqTaken
+		}
+	}
+	entry.ID = len(r.entries) + 1
+	r.entries = append(r.entries, r.reload(*entry))
+	return nil
+}
+
+func (r *fakeAuditRepo) GetLastAuditEntry(houseID int) (*entity.AuditEntry, error) {
+	var last *entity.AuditEntry
+	for i := range r.entries {
+		if r.entries[i].House_id == houseID && (last == nil || r.entries[i].Seq > last.Seq) {
+			last = &r.entries[i]
+		}
+	}
+	return last, nil
+}
+
+func (r *fakeAuditRepo) GetAuditEntries(houseID int, afterSeq int64, limit int) ([]entity.AuditEntry, error) {
+	var entries []entity.AuditEntry
+	for seq := afterSeq + 1; len(entries) < limit; seq++ {
+		found := false
+		for _, entry := range r.entries {
+			if entry.House_id == houseID && entry.Seq == seq {
+				entries = append(entries, entry)
+				found = true
+			}
+		}
+		if !found && !r.hasAfter(houseID, seq) {
+			break
+		}
+	}
+	return entries, nil
+}
+
+func (r *fakeAuditRepo) hasAfter(houseID int, seq int64) bool {
+	for _, entry := range r.entries {
+		if entry.House_id == houseID && entry.Seq > seq {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeAuditRepo) GetAuditHouses() ([]int, error) {
+	seen := map[int]bool{}
+	var houseIDs []int
+	for _, entry := range r.entries {
+		if !seen[entry.House_id] {
+			seen[entry.House_id] = true
+			houseIDs = append(houseIDs, entry.House_id)
+		}
+	}
+	return houseIDs, nil
+}
+
+func (r *fakeAuditRepo) CreateCheckpoint(checkpoint *entity.AuditCheckpoint) error {
+	checkpoint.ID = len(r.checkpoints) + 1
+	r.checkpoints = append(r.checkpoints, *checkpoint)
+	return nil
+}
+
+func (r *fakeAuditRepo) GetCheckpoints(houseID int) ([]entity.AuditCheckpoint, error) {
+	var checkpoints []entity.AuditCheckpoint
+	for _, checkpoint := range r.checkpoints {
+		if checkpoint.House_id == houseID {
+			checkpoints = append(checkpoints, checkpoint)
+		}
+	}
+	return checkpoints, nil
+}
+
+func (r *fakeAuditRepo) GetLastCheckpoint(houseID int) (*entity.AuditCheckpoint, error) {
+	checkpoints, _ := r.GetCheckpoints(houseID)
+	if len(checkpoints) == 0 {
+		return nil, nil
+	}
+	return &checkpoints[len(checkpoints)-1], nil
+}
+
+func recordDoor(t *testing.T, s *auditUsecase, houseID int, commandID int) {
+	t.Helper()
+	userID := 3
+	deviceID := 7
+	err := s.Record(houseID, entity.UserActor(userID, entity.SourceApp), &deviceID, entity.EventDoorCommand, "Door open (issued)",
+		map[string]interface{}{"command_id": commandID, "command": "open", "value": 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditHashAfterReload(t *testing.T) {
+	repo := &fakeAuditRepo{}
+	s := &auditUsecase{auditRepo: repo}
+	recordDoor(t, s, 1, 42)
+
+	stored := repo.entries[0]
+	if _, ok := stored.Params["command_id"].(float64); !ok {
+		t.Fatalf("command_id = %T after the reload, want float64", stored.Params["command_id"])
+	}
+	hash, err := auditHash(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != stored.Hash {
+		t.Errorf("hash of the reloaded entry = %s, want %s", hash, stored.Hash)
+	}
+}
+
+func TestAuditVerify(t *testing.T) {
+	newLog := func() (*fakeAuditRepo, *auditUsecase) {
+		repo := &fakeAuditRepo{}
+		s := &auditUsecase{auditRepo: repo, signingKey: []byte("secret")}
+		for i := 1; i <= 3; i++ {
+			recordDoor(t, s, 1, i)
+		}
+		recordDoor(t, s, 2, 9)
+		if err := s.Checkpoint(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		return repo, s
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(repo *fakeAuditRepo)
+		valid  bool
+		broken int64
+	}{
+		{"untouched", func(repo *fakeAuditRepo) {}, true, 0},
+		{"modified", func(repo *fakeAuditRepo) { repo.entries[1].Description = "Door close" }, false, 2},
+		{"deleted", func(repo *fakeAuditRepo) { repo.entries = append(repo.entries[:1:1], repo.entries[2:]...) }, false, 2},
+		{"rehashed after an edit", func(repo *fakeAuditRepo) {
+			repo.entries[2].Description = "Door close"
+			repo.entries[2].Hash, _ = auditHash(&repo.entries[2])
+		}, false, 3},
+		{"forged checkpoint", func(repo *fakeAuditRepo) { repo.checkpoints[0].Hash = repo.entries[1].Hash }, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, s := newLog()
+			tt.tamper(repo)
+			result, err := s.Verify(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Valid != tt.valid {
+				t.Fatalf("valid = %v, want %v (%s)", result.Valid, tt.valid, result.Error)
+			}
+			if !tt.valid && (result.Broken_seq == nil || *result.Broken_seq != tt.broken) {
+				t.Errorf("broken seq = %v, want %d (%s)", result.Broken_seq, tt.broken, result.Error)
+			}
+			if tt.valid && (result.Entries != 3 || result.Checkpoints != 1 || !result.Signed) {
+				t.Errorf("result = %+v, want 3 entries and 1 signed checkpoint", result)
+			}
+		})
+	}
+}
+
+func TestAuditRecordRetriesTakenSeq(t *testing.T) {
+	repo := &fakeAuditRepo{}
+	s := &auditUsecase{auditRepo: repo}
+	recordDoor(t, s, 1, 1)
+
+	// another process appends entry 2 between the read of the last entry and the insert
+	other := &auditUsecase{auditRepo: &fakeAuditRepo{entries: append([]entity.AuditEntry(nil), repo.entries...)}}
+	recordDoor(t, other, 1, 2)
+	repo.before = &other.auditRepo.(*fakeAuditRepo).entries[1]
+
+	recordDoor(t, s, 1, 3)
+	if len(repo.entries) != 3 || repo.entries[2].Seq != 3 {
+		t.Fatalf("entries = %d, last seq = %d, want the retry to take seq 3", len(repo.entries), repo.entries[len(repo.entries)-1].Seq)
+	}
+	result, err := s.Verify(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Errorf("chain after the retry is broken: %s", result.Error)
+	}
+}
+
+func TestAuditCheckpointWithoutKey(t *testing.T) {
+	repo := &fakeAuditRepo{}
+	s := &auditUsecase{auditRepo: repo}
+	recordDoor(t, s, 1, 1)
+	if err := s.Checkpoint(time.Now()); err != entity.ErrAuditSigningKeyMissing {
+		t.Errorf("err = %v, want %v", err, entity.ErrAuditSigningKeyMissing)
+	}
+	if len(repo.checkpoints) != 0 {
+		t.Errorf("%d checkpoints written without a key", len(repo.checkpoints))
+	}
+}
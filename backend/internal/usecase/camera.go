@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"fmt"
+	entity "go-jwt/internal/entity"
+	"strings"
+)
+
+// RegisterCamera creates the camera with its key, a camera is never left without one. The key is only returned here.
+func (s *deviceUsecase) RegisterCamera(camera *entity.Camera, actor entity.Actor) (string, *entity.DeviceCredential, error) {
+	if err := s.validateCamera(camera); err != nil {
+		return "", nil, err
+	}
+	key, credential, err := newDeviceKey(camera.House_id, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.deviceRepo.CreateCamera(camera, credential); err != nil {
+		return "", nil, err
+	}
+
+	err = s.auditService.Record(camera.House_id, actor, &camera.Device_id, entity.EventDeviceKeyIssued,
+		"Key "+credential.Key_prefix+"... issued", map[string]interface{}{"key_prefix": credential.Key_prefix})
+	if err != nil {
+		fmt.Println("record audit entry failed:", err.Error())
+	}
+	return key, credential, nil
+}
+
+func (s *deviceUsecase) GetCameras(houseID int) ([]entity.Camera, error) {
+	return s.deviceRepo.GetCameras(houseID)
+}
+
+func (s *deviceUsecase) UpdateCamera(camera *entity.Camera) error {
+	if err := s.validateCamera(camera); err != nil {
+		return err
+	}
+	return s.deviceRepo.UpdateCamera(camera)
+}
+
+// validateCamera checks the name, and that the door is a door of the same house
+func (s *deviceUsecase) validateCamera(camera *entity.Camera) error {
+	camera.Name = strings.TrimSpace(camera.Name)
+	if camera.Name == "" || len(camera.Name) > 50 {
+		return fmt.Errorf("%w: name must be between 1 and 50 characters", entity.ErrInvalidCamera)
+	}
+	if camera.Door_id == nil {
+		return nil
+	}
+	door, err := s.deviceRepo.GetDeviceByID(*camera.Door_id)
+	if err != nil || door.Type != "Door" || door.House_id != camera.House_id {
+		return fmt.Errorf("%w: door %d is not a door of the house", entity.ErrInvalidCamera, *camera.Door_id)
+	}
+	return nil
+}
+
+// cameraDoor returns the door the camera opens, 0 for the first door of the house
+func (s *deviceUsecase) cameraDoor(houseID int, cameraID int) int {
+	camera, err := s.deviceRepo.GetCamera(houseID, cameraID)
+	if err != nil || camera.Door_id == nil {
+		return 0
+	}
+	return *camera.Door_id
+}
@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	entity "go-jwt/internal/entity"
+	external "go-jwt/internal/usecase/external"
+	"strconv"
+	"strings"
+)
+
+// Commands understood by the devices of the seeded types, the Adafruit feed of a device type only accepts its
+// own commands. The commands of a device type, the range of their value and their feed are in the Device_type
+// registry.
+const (
+	CommandOn    = "on"
+	CommandOff   = "off"
+	CommandOpen  = "open"
+	CommandClose = "close"
+	CommandLevel = "level" // light level
+	CommandSpeed = "speed" // fan speed
+)
+
+// deviceCommand returns the strategy that sends command to a device of the type, from the route of the command
+// in the registry. The command is checked against the registry before, see CommandUsecase.ValidateCommand.
+func deviceCommand(deviceType *entity.DeviceType, command string, value float64) (external.FeedStrategy, error) {
+	route, ok := deviceType.Routes[command]
+	if !ok || route.Feed == "" || route.Webhook == "" {
+		return nil, fmt.Errorf("%w: %q of %q has no feed to be sent to", entity.ErrInvalidCommand, command, deviceType.Name)
+	}
+	return &external.FeedCommand{
+		FeedName: route.Feed,
+		Webhook:  route.Webhook,
+		Value:    strings.ReplaceAll(route.Payload, "{value}", strconv.FormatFloat(value, 'f', -1, 64)),
+	}, nil
+}
+
+// commandSpec is a command to send to a device, before it is turned into a strategy
+type commandSpec struct {
+	command string
+	value   float64
+}
+
+// setCommands returns the commands that bring a device of deviceType to the data and state stored in a Set:
+// the state command, then the value command when the device is on. A type without state commands, like a
+// sensor, or a type that is not in the registry anymore cannot be set and has no command.
+func setCommands(commands CommandUsecase, deviceType string, data float64, state bool) ([]commandSpec, error) {
+	schema, err := commands.GetDeviceType(deviceType)
+	if errors.Is(err, entity.ErrUnknownDeviceType) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(schema.State_commands) != 2 {
+		return nil, nil
+	}
+
+	var specs []commandSpec
+	if !state {
+		specs = []commandSpec{{schema.State_commands[0], 0}}
+	} else {
+		specs = []commandSpec{{schema.State_commands[1], 0}}
+		if schema.Value_command != "" {
+			specs = append(specs, commandSpec{schema.Value_command, data})
+		}
+	}
+
+	for _, c := range specs {
+		if err := commands.ValidateCommand(deviceType, c.command, c.value); err != nil {
+			return nil, err
+		}
+	}
+	return specs, nil
+}
+
+// commandState is the state a device reports once it applied the command, ok is false for the value command
+func commandState(deviceType *entity.DeviceType, command string) (state bool, ok bool) {
+	if len(deviceType.State_commands) != 2 {
+		return false, false
+	}
+	switch command {
+	case deviceType.State_commands[0]:
+		return false, true
+	case deviceType.State_commands[1]:
+		return true, true
+	}
+	return false, false
+}
@@ -0,0 +1,280 @@
+package usecase
+
+import (
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	external "go-jwt/internal/usecase/external"
+	"math"
+	"time"
+)
+
+var (
+	commandMaxAttempts  = config.Int("COMMAND_MAX_ATTEMPTS", 5)
+	commandAckTimeout   = config.Duration("COMMAND_ACK_TIMEOUT", 20*time.Second)
+	commandRetryBackoff = config.Duration("COMMAND_RETRY_BACKOFF", 2*time.Second)
+)
+
+func NewCommandUsecase(commandRepo repository.CommandRepository, deviceTypes DeviceTypeUsecase, auditService AuditUsecase) CommandUsecase {
+	return &commandUsecase{
+		commandRepo:  commandRepo,
+		deviceTypes:  deviceTypes,
+		auditService: auditService,
+	}
+}
+
+// CommandUsecase is the outbox of the device commands. A command is stored before it is sent, retried with
+// backoff when sending fails, and acknowledged when the device reports the state the command asked for.
+type CommandUsecase interface {
+	SendCommand(houseID int, deviceID int, deviceType string, command string, value float64, description string, actor entity.Actor) (*entity.DeviceCommand, error)
+	ValidateCommand(deviceType string, command string, value float64) error
+	GetDeviceType(name string) (*entity.DeviceType, error)
+	GetCommandsByHouseID(houseID int, status string, roomID *int, limit int) ([]entity.DeviceCommand, error)
+	GetCommandByID(houseID int, commandID int) (*entity.DeviceCommand, error)
+	ProcessOutbox(now time.Time)
+}
+
+type commandUsecase struct {
+	commandRepo  repository.CommandRepository
+	deviceTypes  DeviceTypeUsecase
+	auditService AuditUsecase
+}
+
+// ValidateCommand checks the command against the registry of the device types, and that it can be sent
+func (s *commandUsecase) ValidateCommand(deviceType string, command string, value float64) error {
+	if err := s.deviceTypes.ValidateCommand(deviceType, command, value); err != nil {
+		return err
+	}
+	schema, err := s.deviceTypes.GetDeviceType(deviceType)
+	if err != nil {
+		return err
+	}
+	_, err = deviceCommand(schema, command, value)
+	return err
+}
+
+// GetDeviceType returns the device type the commands are checked with
+func (s *commandUsecase) GetDeviceType(name string) (*entity.DeviceType, error) {
+	return s.deviceTypes.GetDeviceType(name)
+}
+
+// SendCommand stores the command and makes the first attempt right away. deviceID 0 means the first device
+// of deviceType in the house. A failed first attempt is not an error, the command stays queued for a retry.
+// The actor is kept with the command for the activity log.
+func (s *commandUsecase) SendCommand(houseID int, deviceID int, deviceType string, command string, value float64, description string, actor entity.Actor) (*entity.DeviceCommand, error) {
+	if err := s.deviceTypes.ValidateCommand(deviceType, command, value); err != nil {
+		return nil, err
+	}
+	schema, err := s.deviceTypes.GetDeviceType(deviceType)
+	if err != nil {
+		return nil, err
+	}
+	strategy, err := deviceCommand(schema, command, value)
+	if err != nil {
+		return nil, err
+	}
+	if deviceID == 0 {
+		if deviceID, err = s.commandRepo.GetDeviceIDByType(houseID, deviceType); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	feed, payload := strategy.Feed()
+	queued := &entity.DeviceCommand{
+		House_id:    houseID,
+		Device_id:   deviceID,
+		Device_type: deviceType,
+		Command:     command,
+		Value:       value,
+		Feed:        feed,
+		Payload:     payload,
+		Description: description,
+		Status:      entity.CommandQueued,
+		Created_at:  now,
+		Actor:       actor,
+		// keep the worker away while the first attempt is running
+		Next_attempt_at: now.Add(commandAckTimeout),
+	}
+	if err := s.commandRepo.CreateCommand(queued); err != nil {
+		return nil, err
+	}
+	if err := s.commandRepo.SupersedeCommands(houseID, feed, queued.ID); err != nil {
+		fmt.Println("supersede commands failed:", err.Error())
+	}
+	s.auditDoor(queued, entity.EventDoorCommand, "issued")
+
+	s.send(queued, strategy, now)
+	return queued, nil
+}
+
+func (s *commandUsecase) GetCommandsByHouseID(houseID int, status string, roomID *int, limit int) ([]entity.DeviceCommand, error) {
+	return s.commandRepo.GetCommandsByHouseID(houseID, status, roomID, limit)
+}
+
+func (s *commandUsecase) GetCommandByID(houseID int, commandID int) (*entity.DeviceCommand, error) {
+	return s.commandRepo.GetCommandByID(houseID, commandID)
+}
+
+func (s *commandUsecase) ProcessOutbox(now time.Time) {
+	commands, err := s.commandRepo.GetPendingCommands(now)
+	if err != nil {
+		fmt.Println("get pending commands failed:", err.Error())
+		return
+	}
+
+	// the readings of every device are read at most once per run, from its oldest command that was sent
+	since := make(map[int]time.Time)
+	for _, command := range commands {
+		if command.Sent_at == nil {
+			continue
+		}
+		if first, ok := since[command.Device_id]; !ok || command.Sent_at.Before(first) {
+			since[command.Device_id] = *command.Sent_at
+		}
+	}
+	readings := make(map[int][]entity.DataRecord)
+	for i := range commands {
+		command := &commands[i]
+
+		schema, err := s.deviceTypes.GetDeviceType(command.Device_type)
+		var strategy external.FeedStrategy
+		if err == nil {
+			strategy, err = deviceCommand(schema, command.Command, command.Value)
+		}
+		if err != nil {
+			command.Status = entity.CommandFailed
+			command.Last_error = err.Error()
+			s.updateCommand(command)
+			continue
+		}
+
+		if command.Status == entity.CommandQueued {
+			s.send(command, strategy, now)
+			continue
+		}
+
+		// sent, look for the echo. The feed holds the value the server posted itself, only a reading sent by
+		// the device with its key tells that the device followed the command.
+		records, ok := readings[command.Device_id]
+		if !ok && command.Sent_at != nil {
+			records, err = s.commandRepo.GetDataRecordsSince(command.Device_id, since[command.Device_id])
+			if err != nil {
+				fmt.Println("read device data failed:", err.Error())
+			}
+			readings[command.Device_id] = records
+		}
+		if commandEchoed(schema, command, records) {
+			s.acknowledge(command, now)
+			continue
+		}
+
+		if now.After(command.Next_attempt_at) {
+			command.Last_error = "no acknowledgement from the device"
+			if command.Attempts >= commandMaxAttempts {
+				command.Status = entity.CommandFailed
+				s.updateCommand(command)
+				continue
+			}
+			s.send(command, strategy, now)
+		}
+	}
+}
+
+func (s *commandUsecase) send(command *entity.DeviceCommand, strategy external.Strategy, now time.Time) {
+	command.Attempts++
+	err := external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(strategy, nil)
+	if err != nil {
+		fmt.Println("send command failed:", err.Error())
+		command.Last_error = err.Error()
+		if command.Attempts >= commandMaxAttempts {
+			command.Status = entity.CommandFailed
+		} else {
+			command.Status = entity.CommandQueued
+			command.Next_attempt_at = now.Add(commandBackoff(command.Attempts))
+		}
+	} else {
+		command.Status = entity.CommandSent
+		command.Sent_at = &now
+		command.Next_attempt_at = now.Add(commandAckTimeout)
+	}
+	s.updateCommand(command)
+}
+
+func (s *commandUsecase) acknowledge(command *entity.DeviceCommand, now time.Time) {
+	command.Status = entity.CommandAcknowledged
+	command.Acked_at = &now
+	command.Last_error = ""
+	s.updateCommand(command)
+
+	if command.Description != "" {
+		_ = s.commandRepo.CreateActivityLog(&entity.ActivityLog{
+			House_id:      command.House_id,
+			Device:        command.Device_type,
+			Device_id:     &command.Device_id,
+			Time:          now,
+			Type_of_event: command.Description,
+			Actor:         command.Actor,
+			Event_code:    entity.EventCommandAcknowledged,
+			Params:        map[string]interface{}{"command_id": command.ID, "command": command.Command, "value": command.Value},
+		})
+	}
+
+	s.auditDoor(command, entity.EventDoorAcknowledged, "acknowledged")
+}
+
+// auditDoor puts a command of a door in the audit log whoever sent it, once when it is issued and once when
+// the door follows it, so a command the door never followed is in the log too
+func (s *commandUsecase) auditDoor(command *entity.DeviceCommand, code string, step string) {
+	if command.Device_type != "Door" {
+		return
+	}
+	description := command.Description
+	if description == "" {
+		description = "Door " + command.Command
+	}
+	err := s.auditService.Record(command.House_id, command.Actor, &command.Device_id, code, description+" ("+step+")",
+		map[string]interface{}{"command_id": command.ID, "command": command.Command})
+	if err != nil {
+		fmt.Println("record audit entry failed:", err.Error())
+	}
+}
+
+// commandEchoed tells whether the device reported the state of the command after it was sent
+func commandEchoed(deviceType *entity.DeviceType, command *entity.DeviceCommand, records []entity.DataRecord) bool {
+	if command.Sent_at == nil {
+		return false
+	}
+	state, isState := commandState(deviceType, command.Command)
+	for _, record := range records {
+		if !record.Time.After(*command.Sent_at) {
+			continue
+		}
+		if isState && record.Device_state == state {
+			return true
+		}
+		if command.Command == deviceType.Value_command && math.Abs(record.Device_data-command.Value) < 0.5 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *commandUsecase) updateCommand(command *entity.DeviceCommand) {
+	if err := s.commandRepo.UpdateCommand(command); err != nil {
+		fmt.Println("update command failed:", err.Error())
+	}
+}
+
+// commandBackoff doubles the wait after every failed attempt, up to 5 minutes
+func commandBackoff(attempts int) time.Duration {
+	backoff := commandRetryBackoff
+	for i := 1; i < attempts && backoff < 5*time.Minute; i++ {
+		backoff *= 2
+	}
+	if backoff > 5*time.Minute {
+		backoff = 5 * time.Minute
+	}
+	return backoff
+}
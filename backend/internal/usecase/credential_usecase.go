@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// every device key starts with it, so a leaked key is easy to recognize
+const deviceKeyPrefix = "hgs_"
+
+func NewCredentialUsecase(credentialRepo repository.CredentialRepository, monitor MonitorUsecase, auditService AuditUsecase) CredentialUsecase {
+	return &credentialUsecase{
+		credentialRepo: credentialRepo,
+		monitor:        monitor,
+		auditService:   auditService,
+	}
+}
+
+// CredentialUsecase issues, lists and revokes the keys of the devices, and finds the device of a key
+type CredentialUsecase interface {
+	IssueKey(houseID int, deviceID int, actor entity.Actor) (string, *entity.DeviceCredential, error)
+	GetKeys(houseID int, deviceID int) ([]entity.DeviceCredential, error)
+	RevokeKeys(houseID int, deviceID int, actor entity.Actor) error
+	Authenticate(key string) (*entity.Device, error)
+}
+
+type credentialUsecase struct {
+	credentialRepo repository.CredentialRepository
+	monitor        MonitorUsecase
+	auditService   AuditUsecase
+}
+
+// houseDevice returns gorm.ErrRecordNotFound when the device is not in the house
+func (s *credentialUsecase) houseDevice(houseID int, deviceID int) error {
+	device, err := s.credentialRepo.GetDeviceByID(deviceID)
+	if err == nil && device.House_id != houseID {
+		return gorm.ErrRecordNotFound
+	}
+	return err
+}
+
+// IssueKey returns a new key of the device, the previous keys of the device stop working.
+// Rotating a key is issuing a new one.
+func (s *credentialUsecase) IssueKey(houseID int, deviceID int, actor entity.Actor) (string, *entity.DeviceCredential, error) {
+	if err := s.houseDevice(houseID, deviceID); err != nil {
+		return "", nil, err
+	}
+
+	key, credential, err := newDeviceKey(houseID, deviceID)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.credentialRepo.ReplaceCredentials(credential); err != nil {
+		return "", nil, err
+	}
+	s.record(houseID, deviceID, actor, entity.EventDeviceKeyIssued, "Key "+credential.Key_prefix+"... issued",
+		map[string]interface{}{"key_prefix": credential.Key_prefix})
+	return key, credential, nil
+}
+
+// newDeviceKey returns a new key and its credential, only the hash of the key is stored
+func newDeviceKey(houseID int, deviceID int) (string, *entity.DeviceCredential, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := deviceKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	credential := &entity.DeviceCredential{
+		Device_id:  deviceID,
+		House_id:   houseID,
+		Key_hash:   hashDeviceKey(key),
+		Key_prefix: key[:len(deviceKeyPrefix)+6],
+		Created_at: time.Now(),
+	}
+	return key, credential, nil
+}
+
+// GetKeys returns the credentials of the device, without the keys
+func (s *credentialUsecase) GetKeys(houseID int, deviceID int) ([]entity.DeviceCredential, error) {
+	if err := s.houseDevice(houseID, deviceID); err != nil {
+		return nil, err
+	}
+	return s.credentialRepo.GetCredentials(deviceID)
+}
+
+// RevokeKeys stops every key of the device, it is locked out until a new key is issued
+func (s *credentialUsecase) RevokeKeys(houseID int, deviceID int, actor entity.Actor) error {
+	if err := s.houseDevice(houseID, deviceID); err != nil {
+		return err
+	}
+	revoked, err := s.credentialRepo.DeleteCredentials(deviceID)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return entity.ErrCredentialNotFound
+	}
+	s.record(houseID, deviceID, actor, entity.EventDeviceKeyRevoked, fmt.Sprintf("%d key(s) revoked", revoked),
+		map[string]interface{}{"keys": revoked})
+	return nil
+}
+
+// record writes the change of the keys of the device to the audit log, the change is kept when it fails
+func (s *credentialUsecase) record(houseID int, deviceID int, actor entity.Actor, code string, description string, params map[string]interface{}) {
+	if err := s.auditService.Record(houseID, actor, &deviceID, code, description, params); err != nil {
+		fmt.Println("record audit entry failed:", err.Error())
+	}
+}
+
+func (s *credentialUsecase) Authenticate(key string) (*entity.Device, error) {
+	if !strings.HasPrefix(key, deviceKeyPrefix) {
+		return nil, entity.ErrInvalidDeviceKey
+	}
+	credential, err := s.credentialRepo.GetCredentialByHash(hashDeviceKey(key))
+	if err != nil {
+		return nil, err
+	}
+	device, err := s.credentialRepo.GetDeviceByID(credential.Device_id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrInvalidDeviceKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// every request of a device comes with its key, it is the heartbeat of the device
+	now := time.Now()
+	if err := s.credentialRepo.TouchCredential(credential.ID, now); err != nil {
+		fmt.Println("touch credential failed:", err.Error())
+	}
+	s.monitor.Seen(device, now)
+	return device, nil
+}
+
+// the keys are long and random, a plain sha256 is enough to keep them unusable if the table leaks
+func hashDeviceKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
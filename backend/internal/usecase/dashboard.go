@@ -0,0 +1,322 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	entity "go-jwt/internal/entity"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the fire warning of the dashboard, the same as on getDashboardData
+const (
+	fireMinTemperature = 40
+	fireMaxHumidity    = 15
+)
+
+// GetUserDashboard returns a summary of every house of the user, the houses are read at the same time.
+// A house that can not be read fully is still returned with what could be read.
+func (s *userUsecase) GetUserDashboard(userID int) ([]entity.HouseDashboard, error) {
+	houseIDs, err := s.userRepo.GetHouseID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboards := make([]entity.HouseDashboard, len(houseIDs))
+	var wg sync.WaitGroup
+	for i, houseID := range houseIDs {
+		wg.Add(1)
+		go func(i int, houseID int) {
+			defer wg.Done()
+			dashboards[i] = s.houseDashboard(userID, houseID)
+		}(i, houseID)
+	}
+	wg.Wait()
+	return dashboards, nil
+}
+
+func (s *userUsecase) houseDashboard(userID int, houseID int) entity.HouseDashboard {
+	dashboard := entity.HouseDashboard{
+		House_id:   houseID,
+		Sensors:    map[string]*float64{},
+		Devices_on: []string{},
+		Alerts:     []entity.Alert{},
+	}
+	failed := func(part string, err error) {
+		fmt.Println("get dashboard of house", houseID, part, "failed:", err.Error())
+		dashboard.Errors = append(dashboard.Errors, part+": "+err.Error())
+	}
+
+	if house, err := s.userRepo.GetHouseByID(houseID); err != nil {
+		failed("house", err)
+	} else {
+		dashboard.Name = house.Name
+	}
+
+	if unread, err := s.userRepo.CountUnreadNotifications(userID, &houseID); err != nil {
+		failed("notifications", err)
+	} else {
+		dashboard.Unread_notifications = unread
+	}
+
+	devices, err := s.userRepo.GetDevicesByHouseID(houseID)
+	if err != nil {
+		failed("devices", err)
+		return dashboard
+	}
+	dashboard.Devices = len(devices)
+	for i := range devices {
+		device := &devices[i]
+		schema, err := s.deviceTypes.GetDeviceType(device.Type)
+		if err != nil {
+			failed("device "+device.Name, err)
+			continue
+		}
+
+		switch {
+		case isSensor(schema):
+			if _, ok := dashboard.Sensors[device.Type]; !ok {
+				data := device.Data
+				dashboard.Sensors[device.Type] = &data
+			}
+		case isOn(schema, device):
+			dashboard.Devices_on = append(dashboard.Devices_on, device.Name)
+		}
+
+		if !device.Online && device.Last_seen != nil {
+			dashboard.Offline_devices++
+			dashboard.Alerts = append(dashboard.Alerts, entity.Alert{
+				Type:      entity.AlertOffline,
+				Message:   fmt.Sprintf("%s sent nothing since %s", device.Name, device.Last_seen.Format("2006-01-02 15:04")),
+				Device_id: &device.ID,
+			})
+		}
+	}
+
+	temperature, humidity := dashboard.Sensors["Temperature"], dashboard.Sensors["Humidity"]
+	if temperature != nil && humidity != nil && *temperature >= fireMinTemperature && *humidity <= fireMaxHumidity {
+		dashboard.Alerts = append(dashboard.Alerts, entity.Alert{
+			Type:    entity.AlertFire,
+			Message: fmt.Sprintf("Temperature: %g°C, Humidity: %g%%", *temperature, *humidity),
+		})
+	}
+	return dashboard
+}
+
+// isSensor tells whether devices of the type only send a value, like a thermometer
+func isSensor(schema *entity.DeviceType) bool {
+	return len(schema.Commands) == 0 && (schema.Value_kind == entity.ValueNumber || schema.Value_kind == entity.ValueInteger)
+}
+
+// isOn tells whether a device that can be turned on is on now
+func isOn(schema *entity.DeviceType, device *entity.Device) bool {
+	return hasCommand(schema, CommandOn) && device.Data > 0
+}
+
+func hasCommand(schema *entity.DeviceType, command string) bool {
+	for _, known := range schema.Commands {
+		if known == command {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	maxDashboardGroups  = 20
+	maxDashboardWidgets = 50 // per group
+)
+
+// GetDashboardLayout returns the layout of the user for the house, or the default layout when they saved none
+func (s *userUsecase) GetDashboardLayout(userID int, houseID int) (*entity.DashboardLayout, error) {
+	layout, err := s.userRepo.GetDashboardLayout(userID, houseID)
+	if err == nil {
+		return layout, nil
+	}
+	if !errors.Is(err, entity.ErrDashboardLayoutNotFound) {
+		return nil, err
+	}
+
+	devices, err := s.userRepo.GetDevicesByHouseID(houseID)
+	if err != nil {
+		return nil, err
+	}
+	return s.defaultDashboardLayout(userID, houseID, devices)
+}
+
+// defaultDashboardLayout has a group per room, and a group per type for the devices in no room.
+// Every device that has something to show is in it.
+func (s *userUsecase) defaultDashboardLayout(userID int, houseID int, devices []entity.Device) (*entity.DashboardLayout, error) {
+	rooms, err := s.userRepo.GetRooms(houseID)
+	if err != nil {
+		return nil, err
+	}
+	roomNames := make(map[int]string, len(rooms))
+	for _, room := range rooms {
+		roomNames[room.ID] = room.Name
+	}
+
+	layout := &entity.DashboardLayout{User_id: userID, House_id: houseID, Groups: []entity.DashboardGroup{}}
+	groups := map[string]int{}
+	for _, device := range devices {
+		schema, _ := s.deviceTypes.GetDeviceType(device.Type)
+		widget := entity.WidgetValue
+		if schema != nil {
+			if schema.Value_kind == entity.ValueNone {
+				continue
+			}
+			if hasCommand(schema, CommandOn) && hasCommand(schema, CommandOff) {
+				widget = entity.WidgetToggle
+			}
+		}
+
+		key, name := "type "+device.Type, device.Type
+		if device.Room_id != nil && roomNames[*device.Room_id] != "" {
+			key, name = fmt.Sprintf("room %d", *device.Room_id), roomNames[*device.Room_id]
+		}
+		i, ok := groups[key]
+		if !ok {
+			i = len(layout.Groups)
+			groups[key] = i
+			layout.Groups = append(layout.Groups, entity.DashboardGroup{Name: name})
+		}
+		layout.Groups[i].Widgets = append(layout.Groups[i].Widgets, entity.DashboardWidget{Device_id: device.ID, Widget: widget})
+	}
+	return layout, nil
+}
+
+func (s *userUsecase) SaveDashboardLayout(layout *entity.DashboardLayout) error {
+	devices, err := s.userRepo.GetDevicesByHouseID(layout.House_id)
+	if err != nil {
+		return err
+	}
+	if err := s.validateDashboardLayout(layout, devices); err != nil {
+		return err
+	}
+	now := time.Now()
+	layout.Updated_at = &now
+	return s.userRepo.SaveDashboardLayout(layout)
+}
+
+// ResetDashboardLayout deletes the layout of the user, the dashboard is the default one again
+func (s *userUsecase) ResetDashboardLayout(userID int, houseID int) error {
+	return s.userRepo.DeleteDashboardLayout(userID, houseID)
+}
+
+// validateDashboardLayout checks the widgets against the devices of the house, a device is shown only once
+func (s *userUsecase) validateDashboardLayout(layout *entity.DashboardLayout, devices []entity.Device) error {
+	if len(layout.Groups) > maxDashboardGroups {
+		return fmt.Errorf("%w: at most %d groups", entity.ErrInvalidDashboardLayout, maxDashboardGroups)
+	}
+	houseDevices := make(map[int]entity.Device, len(devices))
+	for _, device := range devices {
+		houseDevices[device.ID] = device
+	}
+
+	shown := map[int]bool{}
+	for i := range layout.Groups {
+		group := &layout.Groups[i]
+		group.Name = strings.TrimSpace(group.Name)
+		if group.Name == "" || len(group.Name) > 50 {
+			return fmt.Errorf("%w: group name must be between 1 and 50 characters", entity.ErrInvalidDashboardLayout)
+		}
+		if len(group.Widgets) > maxDashboardWidgets {
+			return fmt.Errorf("%w: at most %d widgets in the group %s", entity.ErrInvalidDashboardLayout, maxDashboardWidgets, group.Name)
+		}
+
+		for j := range group.Widgets {
+			widget := &group.Widgets[j]
+			device, ok := houseDevices[widget.Device_id]
+			if !ok {
+				return fmt.Errorf("%w: device %d is not in the house", entity.ErrInvalidDashboardLayout, widget.Device_id)
+			}
+			if shown[device.ID] {
+				return fmt.Errorf("%w: device %d is shown twice", entity.ErrInvalidDashboardLayout, device.ID)
+			}
+			shown[device.ID] = true
+
+			widget.Label = strings.TrimSpace(widget.Label)
+			if len(widget.Label) > 50 {
+				return fmt.Errorf("%w: label must be at most 50 characters", entity.ErrInvalidDashboardLayout)
+			}
+			if widget.Widget == "" {
+				widget.Widget = entity.WidgetValue
+			}
+
+			schema, err := s.deviceTypes.GetDeviceType(device.Type)
+			if err != nil {
+				return err
+			}
+			switch widget.Widget {
+			case entity.WidgetValue:
+				if schema.Value_kind == entity.ValueNone {
+					return fmt.Errorf("%w: %s has no value to show", entity.ErrInvalidDashboardLayout, device.Name)
+				}
+			case entity.WidgetToggle:
+				if !hasCommand(schema, CommandOn) || !hasCommand(schema, CommandOff) {
+					return fmt.Errorf("%w: %s can not be turned on and off", entity.ErrInvalidDashboardLayout, device.Name)
+				}
+			default:
+				return fmt.Errorf("%w: unknown widget '%s'", entity.ErrInvalidDashboardLayout, widget.Widget)
+			}
+		}
+	}
+	return nil
+}
+
+// GetHouseDashboard returns the current data of the devices in the layout of the user.
+// A device deleted since the layout was saved is left out.
+func (s *userUsecase) GetHouseDashboard(userID int, houseID int) (*entity.Dashboard, error) {
+	devices, err := s.userRepo.GetDevicesByHouseID(houseID)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := s.userRepo.GetDashboardLayout(userID, houseID)
+	if errors.Is(err, entity.ErrDashboardLayoutNotFound) {
+		layout, err = s.defaultDashboardLayout(userID, houseID, devices)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	houseDevices := make(map[int]*entity.Device, len(devices))
+	for i := range devices {
+		houseDevices[devices[i].ID] = &devices[i]
+	}
+
+	dashboard := &entity.Dashboard{House_id: houseID, Groups: make([]entity.DashboardGroupData, 0, len(layout.Groups))}
+	for _, group := range layout.Groups {
+		data := entity.DashboardGroupData{Name: group.Name, Widgets: []entity.WidgetData{}}
+		for _, widget := range group.Widgets {
+			device, ok := houseDevices[widget.Device_id]
+			if !ok {
+				continue
+			}
+			data.Widgets = append(data.Widgets, s.widgetData(widget, device))
+		}
+		dashboard.Groups = append(dashboard.Groups, data)
+	}
+	return dashboard, nil
+}
+
+func (s *userUsecase) widgetData(widget entity.DashboardWidget, device *entity.Device) entity.WidgetData {
+	if widget.Label == "" {
+		widget.Label = device.Name
+	}
+	data := entity.WidgetData{
+		DashboardWidget: widget,
+		Device_type:     device.Type,
+		Data:            device.Data,
+		Online:          device.Online,
+		Last_seen:       device.Last_seen,
+	}
+	// a device of a type missing from the registry only has its raw data
+	if schema, err := s.deviceTypes.GetDeviceType(device.Type); err == nil {
+		data.Unit = schema.Unit
+		if index := int(device.Data); schema.Value_kind == entity.ValueEnum && index >= 0 && index < len(schema.States) {
+			data.State = schema.States[index]
+		}
+	}
+	return data
+}
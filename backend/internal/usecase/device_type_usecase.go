@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	"math"
+	"sync"
+	"time"
+)
+
+// a change in the Device_type table is seen after at most this long
+var deviceTypeCacheTTL = config.Duration("DEVICE_TYPE_CACHE_TTL", 5*time.Minute)
+
+func NewDeviceTypeUsecase(deviceTypeRepo repository.DeviceTypeRepository) DeviceTypeUsecase {
+	return &deviceTypeUsecase{
+		deviceTypeRepo: deviceTypeRepo,
+	}
+}
+
+// DeviceTypeUsecase is the registry of the device types. The ingestion checks the data of the devices
+// and the command outbox checks the commands with it.
+type DeviceTypeUsecase interface {
+	GetDeviceTypes() ([]entity.DeviceType, error)
+	GetDeviceType(name string) (*entity.DeviceType, error)
+	ValidateData(deviceType string, data float64) error
+	ValidateCommand(deviceType string, command string, value float64) error
+}
+
+type deviceTypeUsecase struct {
+	deviceTypeRepo repository.DeviceTypeRepository
+
+	mu       sync.Mutex
+	types    map[string]*entity.DeviceType
+	loadedAt time.Time
+}
+
+func (s *deviceTypeUsecase) load() (map[string]*entity.DeviceType, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.types != nil && time.Since(s.loadedAt) < deviceTypeCacheTTL {
+		return s.types, nil
+	}
+
+	deviceTypes, err := s.deviceTypeRepo.GetDeviceTypes()
+	if err != nil {
+		if s.types != nil {
+			// keep the registry we have rather than refusing every device
+			fmt.Println("reload device types failed:", err.Error())
+			return s.types, nil
+		}
+		return nil, err
+	}
+	types := make(map[string]*entity.DeviceType, len(deviceTypes))
+	for i := range deviceTypes {
+		types[deviceTypes[i].Name] = &deviceTypes[i]
+	}
+	s.types, s.loadedAt = types, time.Now()
+	return types, nil
+}
+
+func (s *deviceTypeUsecase) GetDeviceTypes() ([]entity.DeviceType, error) {
+	return s.deviceTypeRepo.GetDeviceTypes()
+}
+
+func (s *deviceTypeUsecase) GetDeviceType(name string) (*entity.DeviceType, error) {
+	types, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	deviceType, ok := types[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", entity.ErrUnknownDeviceType, name)
+	}
+	return deviceType, nil
+}
+
+func (s *deviceTypeUsecase) ValidateData(deviceType string, data float64) error {
+	schema, err := s.GetDeviceType(deviceType)
+	if err != nil {
+		return err
+	}
+	if err := checkValue(schema, data); err != nil {
+		return fmt.Errorf("%w: %s", entity.ErrInvalidDeviceData, err.Error())
+	}
+	return nil
+}
+
+// ValidateCommand checks the command is in the vocabulary of the device type,
+// and the value of the command that sets the value like the data of the device
+func (s *deviceTypeUsecase) ValidateCommand(deviceType string, command string, value float64) error {
+	schema, err := s.GetDeviceType(deviceType)
+	if err != nil {
+		return fmt.Errorf("%w: %s", entity.ErrInvalidCommand, err.Error())
+	}
+	for _, known := range schema.Commands {
+		if known != command {
+			continue
+		}
+		if command == schema.Value_command {
+			if err := checkValue(schema, value); err != nil {
+				return fmt.Errorf("%w: %s", entity.ErrInvalidCommand, err.Error())
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q is not a command of %q", entity.ErrInvalidCommand, command, deviceType)
+}
+
+func checkValue(schema *entity.DeviceType, value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%s value must be a number", schema.Name)
+	}
+	switch schema.Value_kind {
+	case entity.ValueNone:
+		return nil
+	case entity.ValueEnum:
+		index := int(value)
+		if value != float64(index) || index < 0 || index >= len(schema.States) {
+			return fmt.Errorf("%s value must be the index of one of %v", schema.Name, schema.States)
+		}
+		return nil
+	case entity.ValueInteger:
+		if value != math.Trunc(value) {
+			return fmt.Errorf("%s value must be a whole number", schema.Name)
+		}
+	}
+	if schema.Min_value != nil && value < *schema.Min_value {
+		return fmt.Errorf("%s value cannot be less than %g %s", schema.Name, *schema.Min_value, schema.Unit)
+	}
+	if schema.Max_value != nil && value > *schema.Max_value {
+		return fmt.Errorf("%s value cannot be more than %g %s", schema.Name, *schema.Max_value, schema.Unit)
+	}
+	return nil
+}
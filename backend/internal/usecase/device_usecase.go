@@ -2,68 +2,223 @@ package usecase
 
 import (
 	"bytes"
+	"fmt"
+	"go-jwt/internal/blobstore"
 	entity "go-jwt/internal/entity"
 	repository "go-jwt/internal/infrastructure/repository"
 	external "go-jwt/internal/usecase/external"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
-func NewDeviceUsecase(deviceRepo repository.DeviceRepository) DeviceUsecase {
+func NewDeviceUsecase(deviceRepo repository.DeviceRepository, commandService CommandUsecase, deviceTypes DeviceTypeUsecase, monitor MonitorUsecase, faceProvider external.FaceProvider, blobStore blobstore.Store, auditService AuditUsecase) DeviceUsecase {
 	return &deviceUsecase{
-		deviceRepo: deviceRepo,
+		deviceRepo:     deviceRepo,
+		commandService: commandService,
+		deviceTypes:    deviceTypes,
+		monitor:        monitor,
+		faceProvider:   faceProvider,
+		blobStore:      blobStore,
+		auditService:   auditService,
 	}
 }
 
 type DeviceUsecase interface {
-	UpdateTemperature(id int, temperature float64) error
-	UpdateHumidity(id int, humid float64) error
-	UpdateFanSpeed(id int, speed int) error
-	UpdateDevice(houseID int, deviceID int, deviceType string, data float64, state bool) error
-	UpdateFaceEncodings(houseID int, faceEncode string) error
+	UpdateTemperature(device *entity.Device, temperature float64) error
+	UpdateHumidity(device *entity.Device, humid float64) error
+	UpdateFanSpeed(device *entity.Device, speed int) error
+	UpdateDevice(device *entity.Device, data float64, state bool) error
+	RecordTelemetry(device *entity.Device, readings []entity.Reading) (int64, error)
+	GetDevices(houseID int, roomID *int) ([]entity.Device, error)
+	GetDataStats(houseID int, deviceID int, from time.Time, to time.Time) (*entity.DataStats, error)
+	UpdateFaceEncodings(houseID int, personID *int, faceEncode string) error
 	GetFaceEncoding(houseID int) ([]string, error)
+	CreateFacePerson(person *entity.FacePerson) error
+	GetFacePeople(houseID int) ([]entity.FacePerson, []entity.FaceEncoding, error)
+	UpdateFacePerson(person *entity.FacePerson) error
+	DeleteFacePerson(houseID int, personID int) error
+	AssignFaceEncoding(houseID int, encodingID int, personID *int) error
+	DeleteFaceEncoding(houseID int, encodingID int) error
 	EncodeFace(houseID int, formData *bytes.Buffer, ContentType string, data *map[string]interface{}) error
 	VerifyFace(houseID int, formData *bytes.Buffer, ContentType string, data *map[string]interface{}) error
-	OpenDoorAfterFaceVerified(houseID int) error
+	FaceProviderHealth() (string, error)
+	EncodeImage(houseID int, image []byte, filename string, contentType string) (string, error)
+	RecognizeFace(cameraID int, image []byte, filename string, contentType string) (*entity.FaceMatch, *entity.DeviceCommand, error)
+	RegisterCamera(camera *entity.Camera, actor entity.Actor) (string, *entity.DeviceCredential, error)
+	GetCameras(houseID int) ([]entity.Camera, error)
+	UpdateCamera(camera *entity.Camera) error
+	GetSnapshots(houseID int, limit int) ([]entity.Snapshot, error)
+	GetSnapshotImage(houseID int, snapshotID int) (*entity.Snapshot, []byte, error)
+	PruneSnapshots(now time.Time)
 	CreateActivityLog(*entity.ActivityLog) error
 }
 
 type deviceUsecase struct {
-	deviceRepo repository.DeviceRepository
+	deviceRepo     repository.DeviceRepository
+	commandService CommandUsecase
+	deviceTypes    DeviceTypeUsecase
+	monitor        MonitorUsecase
+	faceProvider   external.FaceProvider
+	blobStore      blobstore.Store
+	auditService   AuditUsecase
+}
+
+// UpdateTemperature writes the temperature sent by the device, only a temperature sensor can send one
+func (s *deviceUsecase) UpdateTemperature(device *entity.Device, temperature float64) error {
+	if device.Type != "Temperature" {
+		return entity.ErrWrongDeviceType
+	}
+	return s.UpdateDevice(device, temperature, true)
+}
+
+func (s *deviceUsecase) UpdateHumidity(device *entity.Device, humid float64) error {
+	if device.Type != "Humidity" {
+		return entity.ErrWrongDeviceType
+	}
+	return s.UpdateDevice(device, humid, true)
 }
 
-func (s *deviceUsecase) UpdateTemperature(id int, temperature float64) error {
-	return s.deviceRepo.UpdateTemperature(id, temperature)
+func (s *deviceUsecase) UpdateFanSpeed(device *entity.Device, speed int) error {
+	if device.Type != "Fan" {
+		return entity.ErrWrongDeviceType
+	}
+	return s.UpdateDevice(device, float64(speed), speed > 0)
 }
 
-func (s *deviceUsecase) UpdateHumidity(id int, humid float64) error {
-	return s.deviceRepo.UpdateHumidity(id, humid)
+// UpdateDevice records a single reading taken now, like a telemetry of one reading
+func (s *deviceUsecase) UpdateDevice(device *entity.Device, data float64, state bool) error {
+	_, err := s.RecordTelemetry(device, []entity.Reading{{Data: data, State: state}})
+	return err
 }
 
-func (s *deviceUsecase) UpdateFanSpeed(id int, speed int) error {
-	return s.deviceRepo.UpdateFanSpeed(id, speed)
+// GetDevices returns the devices of the house, or of one of its rooms, with the schema of their type
+func (s *deviceUsecase) GetDevices(houseID int, roomID *int) ([]entity.Device, error) {
+	devices, err := s.deviceRepo.GetDevices(houseID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		// a device of a type missing from the registry is still listed, without schema
+		devices[i].Schema, _ = s.deviceTypes.GetDeviceType(devices[i].Type)
+	}
+	return devices, nil
 }
 
-func (s *deviceUsecase) UpdateDevice(houseID int, deviceID int, deviceType string, data float64, state bool) error {
-	return s.deviceRepo.UpdateDevice(houseID, deviceID, deviceType, data, state)
+// GetDataStats returns the statistics of the data of a device of the house, without the anomalies
+func (s *deviceUsecase) GetDataStats(houseID int, deviceID int, from time.Time, to time.Time) (*entity.DataStats, error) {
+	device, err := s.deviceRepo.GetDeviceByID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.House_id != houseID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.deviceRepo.GetDataStats(deviceID, from, to)
 }
 
-func (s *deviceUsecase) UpdateFaceEncodings(houseID int, faceEncode string) error {
-	return s.deviceRepo.UpdateFaceEncodings(houseID, faceEncode)
+func (s *deviceUsecase) UpdateFaceEncodings(houseID int, personID *int, faceEncode string) error {
+	if personID != nil {
+		if _, err := s.deviceRepo.GetFacePerson(houseID, *personID); err != nil {
+			return err
+		}
+	}
+	return s.deviceRepo.UpdateFaceEncodings(houseID, personID, faceEncode)
 }
 
 func (s *deviceUsecase) GetFaceEncoding(houseID int) ([]string, error) {
 	return s.deviceRepo.GetFaceEncoding(houseID)
 }
 
+func (s *deviceUsecase) CreateFacePerson(person *entity.FacePerson) error {
+	if err := validateFacePerson(person); err != nil {
+		return err
+	}
+	person.Created_at = time.Now()
+	return s.deviceRepo.CreateFacePerson(person)
+}
+
+// GetFacePeople returns the people of the house with their encodings, and the encodings that belong to nobody.
+// The encodings themselves are left out, they are only useful to the face recognition service.
+func (s *deviceUsecase) GetFacePeople(houseID int) ([]entity.FacePerson, []entity.FaceEncoding, error) {
+	people, err := s.deviceRepo.GetFacePeople(houseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	encodings, err := s.deviceRepo.GetFaceEncodings(houseID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := make(map[int]int, len(people))
+	for i := range people {
+		people[i].Encodings = []entity.FaceEncoding{}
+		index[people[i].ID] = i
+	}
+	unassigned := []entity.FaceEncoding{}
+	for _, encoding := range encodings {
+		encoding.Face_encoding = ""
+		if i, ok := index[derefInt(encoding.Person_id)]; ok && encoding.Person_id != nil {
+			people[i].Encodings = append(people[i].Encodings, encoding)
+		} else {
+			unassigned = append(unassigned, encoding)
+		}
+	}
+	return people, unassigned, nil
+}
+
+func (s *deviceUsecase) UpdateFacePerson(person *entity.FacePerson) error {
+	if err := validateFacePerson(person); err != nil {
+		return err
+	}
+	return s.deviceRepo.UpdateFacePerson(person)
+}
+
+func (s *deviceUsecase) DeleteFacePerson(houseID int, personID int) error {
+	return s.deviceRepo.DeleteFacePerson(houseID, personID)
+}
+
+// AssignFaceEncoding gives an encoding to a person, or to nobody when personID is nil
+func (s *deviceUsecase) AssignFaceEncoding(houseID int, encodingID int, personID *int) error {
+	if personID != nil {
+		if _, err := s.deviceRepo.GetFacePerson(houseID, *personID); err != nil {
+			return err
+		}
+	}
+	return s.deviceRepo.AssignFaceEncoding(houseID, encodingID, personID)
+}
+
+func (s *deviceUsecase) DeleteFaceEncoding(houseID int, encodingID int) error {
+	return s.deviceRepo.DeleteFaceEncoding(houseID, encodingID)
+}
+
+func validateFacePerson(person *entity.FacePerson) error {
+	person.Name = strings.TrimSpace(person.Name)
+	if person.Name == "" || len(person.Name) > 100 {
+		return fmt.Errorf("%w: name must be between 1 and 100 characters", entity.ErrInvalidFacePerson)
+	}
+	return nil
+}
+
+func derefInt(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
 func (s *deviceUsecase) EncodeFace(houseID int, formData *bytes.Buffer, ContentType string, data *map[string]interface{}) error {
-	return external.NewExternalServiceAdapter(&external.FaceRecognitionService{}).Execute(&external.EncodeFace{FormData: formData, ContentType: ContentType}, data)
+	return s.faceProvider.Encode(formData, ContentType, data)
 }
 
 func (s *deviceUsecase) VerifyFace(houseID int, formData *bytes.Buffer, ContentType string, data *map[string]interface{}) error {
-	return external.NewExternalServiceAdapter(&external.FaceRecognitionService{}).Execute(&external.VerifyFace{FormData: formData, ContentType: ContentType}, data)
+	return s.faceProvider.Verify(formData, ContentType, data)
 }
 
-func (s *deviceUsecase) OpenDoorAfterFaceVerified(houseID int) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.DoorOpen{}, nil)
+// FaceProviderHealth returns the name of the face provider and why it is down, if it is
+func (s *deviceUsecase) FaceProviderHealth() (string, error) {
+	return s.faceProvider.Name(), s.faceProvider.Health()
 }
 
 func (s *deviceUsecase) CreateActivityLog(activityLog *entity.ActivityLog) error {
@@ -1,14 +1,16 @@
 package usecase
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
+	"go-jwt/internal/config"
 	"net/http"
-	"strconv"
+	"time"
 )
 
+// the feeds are read from the account of ADAFRUIT_USERNAME
+var adafruitFeedsURL = "https://io.adafruit.com/api/v2/" + config.String("ADAFRUIT_USERNAME", "QuangThien15") + "/feeds/"
+
 type AdaFruitService struct {
 	ExternalService
 }
@@ -17,134 +19,55 @@ func (a *AdaFruitService) Execute(strategy Strategy, des any) error {
 	return strategy.Execute(des)
 }
 
-type LightOn struct {
-}
-
-type LightOff struct {
-}
-
-type LightLevel struct {
-	LightLevel float64
-}
-
-type FanSpeed struct {
-	FanSpeed float64
-}
-
-type FanOn struct {
-}
-
-type FanOff struct {
-}
-
-type DoorOpen struct {
-}
-
-type DoorClose struct {
-}
-
-func (l *LightOn) Execute(des any) error {
-	jsonData := map[string]string{
-		"value": "Alarm On",
-	}
-	// Send request to Adafruit to turn on the light
-	err := SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/Ye9oEbz9VvPgzjLYzjz7dDC8R1dL", jsonData, des)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (l *LightOff) Execute(des any) error {
-	jsonData := map[string]string{
-		"value": "Alarm Off",
-	}
-	// Send request to Adafruit to turn off the light
-	err := SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/Ye9oEbz9VvPgzjLYzjz7dDC8R1dL", jsonData, des)
-	if err != nil {
-		return err
-	}
-	return nil
+// FeedStrategy is a strategy that writes a value to an Adafruit feed. The device reads the feed and reports
+// the state it applied with its key, which is how a command is known to be acknowledged.
+type FeedStrategy interface {
+	Strategy
+	Feed() (feed string, value string)
 }
 
-func (l *LightLevel) Execute(des any) error {
-	jsonData := map[string]string{
-		"value": strconv.FormatFloat(l.LightLevel, 'f', -1, 64),
-	}
-
-	// Send request to Adafruit to set the light level
-	err := SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/YUgssBNR6j1J24jF6RDYG71QqH4c", jsonData, des)
-	if err != nil {
-		return err
-	}
-	return nil
+// FeedCommand writes Value to an Adafruit feed through the webhook of the feed, the device type registry
+// tells the feed, the webhook and the value of every command
+type FeedCommand struct {
+	FeedName string
+	Webhook  string
+	Value    string
 }
 
-func (f *FanSpeed) Execute(des any) error {
-	jsonData := map[string]string{
-		"value": strconv.FormatFloat(f.FanSpeed, 'f', -1, 64),
-	}
-
-	// Send request to Adafruit to set the fan speed
-	err := SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/GDfmkBYDyWBUV6A6M17stLHytSEM", jsonData, des)
-	if err != nil {
-		return err
-	}
-	return nil
-}
+func (c *FeedCommand) Feed() (string, string) { return c.FeedName, c.Value }
 
-func (f *FanOn) Execute(des any) error {
+func (c *FeedCommand) Execute(des any) error {
 	jsonData := map[string]string{
-		"value": "Fan On",
-	}
-
-	// Send request to Adafruit to turn on the fan
-	err := SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/9xJ4R9ZM7A9tKEeJcaJh9rS7t6L5", jsonData, des)
-	if err != nil {
-		return err
+		"value": c.Value,
 	}
-	return nil
+	return SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/"+c.Webhook, jsonData, des)
 }
 
-func (f *FanOff) Execute(des any) error {
-	jsonData := map[string]string{
-		"value": "Fan Off",
-	}
-
-	// Send request to Adafruit to turn off the fan
-	err := SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/9xJ4R9ZM7A9tKEeJcaJh9rS7t6L5", jsonData, des)
-	if err != nil {
-		return err
-	}
-	return nil
+// FeedValue is the last value of a feed
+type FeedValue struct {
+	Value      string    `json:"value"`
+	Created_at time.Time `json:"created_at"`
 }
 
-func (d *DoorOpen) Execute(des any) error {
-	jsonData := map[string]string{
-		"value": "Open Door",
-	}
-
-	// Send request to Adafruit to open the door
-	err := SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/iMQFZUbNRJPM5ZCzRN4ped6GbL4W", jsonData, des)
+// GetLastFeedValue reads the last value written to the feed
+func GetLastFeedValue(feed string) (*FeedValue, error) {
+	body, err := AdafruitClient.Do(http.MethodGet, adafruitFeedsURL+feed+"/data?limit=1", "", nil, true)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
-}
 
-func (d *DoorClose) Execute(des any) error {
-	jsonData := map[string]string{
-		"value": "Close Door",
+	var data []FeedValue
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
 	}
-
-	// Send request to Adafruit to close the door
-	err := SendRequest("https://io.adafruit.com/api/v2/webhooks/feed/iMQFZUbNRJPM5ZCzRN4ped6GbL4W", jsonData, des)
-	if err != nil {
-		return err
+	if len(data) == 0 {
+		return nil, fmt.Errorf("feed %s has no data", feed)
 	}
-	return nil
+	return &data[0], nil
 }
 
+// SendRequest posts jsonData to an Adafruit webhook. The webhook appends a value to the feed,
+// so it is not sent again on failure, the command outbox takes care of the retries.
 func SendRequest(baseURL string, jsonData map[string]string, des any) error {
 	// Convert JSON data to bytes
 	jsonDataBytes, err := json.Marshal(jsonData)
@@ -153,35 +76,12 @@ func SendRequest(baseURL string, jsonData map[string]string, des any) error {
 		return err
 	}
 
-	// Create a new HTTP client
-	client := &http.Client{}
-
-	// Create a POST request with the JSON data
-	req, err := http.NewRequest(http.MethodPost, baseURL, bytes.NewReader(jsonDataBytes))
-	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return err
-	}
-
-	// Set the request headers
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send the request
-	resp, err := client.Do(req)
+	body, err := AdafruitClient.Do(http.MethodPost, baseURL, "application/json", jsonDataBytes, false)
 	if err != nil {
 		fmt.Println("Error sending request:", err)
 		return err
 	}
 
-	defer resp.Body.Close()
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return err
-	}
-
 	if des != nil {
 		err = json.Unmarshal(body, &des)
 		if err != nil {
@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The shared clients of the external services, every request to a service goes through its client
+var (
+	AdafruitClient = NewClient("adafruit",
+		config.Duration("ADAFRUIT_TIMEOUT", 10*time.Second),
+		config.Int("ADAFRUIT_RETRIES", 2))
+	// the face service sleeps when idle and takes a while to wake up. Its requests are never sent twice,
+	// a camera waiting on a retry of a 60s request would give up long before the answer.
+	FaceClient = NewClient("face recognition service",
+		config.Duration("FACE_SERVICE_TIMEOUT", 60*time.Second), 0)
+)
+
+var (
+	breakerThreshold = config.Int("CIRCUIT_BREAKER_THRESHOLD", 5)
+	breakerCooldown  = config.Duration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second)
+)
+
+// Client sends the requests of one service with a timeout, retries the idempotent ones,
+// and fails fast with entity.ErrCircuitOpen after too many consecutive failures
+type Client struct {
+	name    string
+	http    *http.Client
+	retries int
+	breaker *breaker
+}
+
+func NewClient(name string, timeout time.Duration, retries int) *Client {
+	return &Client{
+		name:    name,
+		http:    &http.Client{Timeout: timeout},
+		retries: retries,
+		breaker: &breaker{threshold: breakerThreshold, cooldown: breakerCooldown},
+	}
+}
+
+// Do sends the request and returns the body of a 2xx response. A request is only sent again when
+// idempotent is true, and only after a network error, a timeout or a 5xx response.
+func (c *Client) Do(method string, url string, contentType string, body []byte, idempotent bool) ([]byte, error) {
+	attempts := 1
+	if idempotent {
+		attempts += c.retries
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		if !c.breaker.allow() {
+			return nil, fmt.Errorf("%s: %w", c.name, entity.ErrCircuitOpen)
+		}
+
+		var respBody []byte
+		respBody, err = c.do(method, url, contentType, body)
+		var statusErr *entity.ServiceStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode < 500 {
+			// the service is up, the request is wrong and sending it again will not help
+			c.breaker.success()
+			return nil, err
+		}
+		if err == nil {
+			c.breaker.success()
+			return respBody, nil
+		}
+		c.breaker.failure()
+	}
+	return nil, err
+}
+
+func (c *Client) do(method string, url string, contentType string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || isTimeout(err) {
+			return nil, fmt.Errorf("%s: %w", c.name, entity.ErrServiceTimeout)
+		}
+		return nil, fmt.Errorf("%s: %w: %s", c.name, entity.ErrServiceUnreachable, err.Error())
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", c.name, entity.ErrServiceUnreachable, err.Error())
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &entity.ServiceStatusError{Service: c.name, StatusCode: resp.StatusCode, Message: errorMessage(respBody)}
+	}
+	return respBody, nil
+}
+
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// errorMessage takes the "error" of a JSON body, or the body itself
+func errorMessage(body []byte) string {
+	var data map[string]interface{}
+	if json.Unmarshal(body, &data) == nil {
+		if message, ok := data["error"].(string); ok {
+			return message
+		}
+	}
+	message := strings.TrimSpace(string(body))
+	if len(message) > 200 {
+		message = message[:200]
+	}
+	return message
+}
+
+// breaker opens after threshold consecutive failures. Once cooldown has passed it lets one request
+// through, which closes it again on success or keeps it open for another cooldown on failure.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
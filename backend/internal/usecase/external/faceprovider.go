@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"go-jwt/internal/facematch"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FaceProvider extracts the encoding of a face and compares faces. The forms and the answers are
+// the ones of the face recognition service: "img" and "encoding_array" in, JSON out.
+type FaceProvider interface {
+	Name() string
+	Encode(formData *bytes.Buffer, contentType string, des any) error
+	Verify(formData *bytes.Buffer, contentType string, des any) error
+	Health() error
+}
+
+// NewFaceProvider returns the provider named by FACE_PROVIDER: "http" for the face recognition service,
+// "stub" for the local provider of development
+func NewFaceProvider(name string) (FaceProvider, error) {
+	switch name {
+	case "http":
+		return &HTTPFaceProvider{}, nil
+	case "stub":
+		return &StubFaceProvider{}, nil
+	}
+	return nil, fmt.Errorf("unknown face provider %q", name)
+}
+
+// HTTPFaceProvider sends the faces to the face recognition service at FACE_SERVICE_URL
+type HTTPFaceProvider struct {
+}
+
+func (p *HTTPFaceProvider) Name() string {
+	return "http"
+}
+
+func (p *HTTPFaceProvider) Encode(formData *bytes.Buffer, contentType string, des any) error {
+	return NewExternalServiceAdapter(&FaceRecognitionService{}).Execute(&EncodeFace{FormData: formData, ContentType: contentType}, des)
+}
+
+func (p *HTTPFaceProvider) Verify(formData *bytes.Buffer, contentType string, des any) error {
+	return NewExternalServiceAdapter(&FaceRecognitionService{}).Execute(&VerifyFace{FormData: formData, ContentType: contentType}, des)
+}
+
+// Health only checks that the service answers, it is not retried so a sleeping service shows as down
+func (p *HTTPFaceProvider) Health() error {
+	_, err := FaceClient.Do(http.MethodGet, faceServiceURL+"/", "", nil, false)
+	return err
+}
+
+// StubFaceProvider never leaves the process. The encoding of an image is derived from its sha256,
+// so the same image always matches itself and two different images never match.
+type StubFaceProvider struct {
+}
+
+// the length of the encodings of the face_recognition library
+const stubEncodingLength = 128
+
+func (p *StubFaceProvider) Name() string {
+	return "stub"
+}
+
+func (p *StubFaceProvider) Encode(formData *bytes.Buffer, contentType string, des any) error {
+	image, _, err := readFaceForm(formData, contentType)
+	if err != nil {
+		return err
+	}
+	if len(image) == 0 {
+		return answer(map[string]interface{}{"error": "No face found in the image"}, des)
+	}
+	return answer(map[string]interface{}{"face_encoding": formatEncoding(stubEncoding(image))}, des)
+}
+
+func (p *StubFaceProvider) Verify(formData *bytes.Buffer, contentType string, des any) error {
+	image, encodings, err := readFaceForm(formData, contentType)
+	if err != nil {
+		return err
+	}
+	if len(image) == 0 {
+		return answer(map[string]interface{}{"error": "No face found in the image"}, des)
+	}
+
+	probe := stubEncoding(image)
+	distances := make([]float64, len(encodings))
+	isMatch := false
+	for i, encoding := range encodings {
+		distances[i] = 1
+		if vector, err := facematch.ParseEncoding(encoding); err == nil {
+			if distance, err := facematch.Distance(facematch.MetricEuclidean, probe, vector); err == nil {
+				distances[i] = distance
+			}
+		}
+		isMatch = isMatch || distances[i] <= 0.6
+	}
+	return answer(map[string]interface{}{"is_match": isMatch, "distances": distances}, des)
+}
+
+func (p *StubFaceProvider) Health() error {
+	return nil
+}
+
+// readFaceForm returns the "img" file and the "encoding_array" fields of the form
+func readFaceForm(formData *bytes.Buffer, contentType string) ([]byte, []string, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	form, err := multipart.NewReader(bytes.NewReader(formData.Bytes()), params["boundary"]).ReadForm(32 << 20)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer form.RemoveAll()
+
+	var image []byte
+	if files := form.File["img"]; len(files) > 0 {
+		file, err := files[0].Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+		if image, err = io.ReadAll(file); err != nil {
+			return nil, nil, err
+		}
+	}
+	return image, form.Value["encoding_array"], nil
+}
+
+// stubEncoding spreads the sha256 of the image over values between -0.1 and 0.1
+func stubEncoding(image []byte) []float64 {
+	encoding := make([]float64, stubEncodingLength)
+	seed := sha256.Sum256(image)
+	for i := range encoding {
+		block := sha256.Sum256(append(seed[:], byte(i)))
+		encoding[i] = (float64(binary.BigEndian.Uint32(block[:4]))/float64(^uint32(0)) - 0.5) / 5
+	}
+	return encoding
+}
+
+// formatEncoding writes the encoding as a JSON array, which facematch.ParseEncoding reads back
+func formatEncoding(encoding []float64) string {
+	values := make([]string, len(encoding))
+	for i, value := range encoding {
+		values[i] = strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// answer decodes the answer into des like a JSON response of the service
+func answer(data map[string]interface{}, des any) error {
+	if des == nil {
+		return nil
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, des)
+}
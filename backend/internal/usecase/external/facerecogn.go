@@ -3,10 +3,12 @@ package usecase
 import (
 	"bytes"
 	"encoding/json"
-	"io"
+	"go-jwt/internal/config"
 	"net/http"
 )
 
+var faceServiceURL = config.String("FACE_SERVICE_URL", "https://face-reg-service-latest.onrender.com")
+
 type FaceRecognitionService struct {
 	ExternalService
 }
@@ -27,7 +29,7 @@ type VerifyFace struct {
 
 func (e *EncodeFace) Execute(des any) error {
 	// Send request to FaceRecognition to encode the face
-	err := SendRequestWithFormData("https://face-reg-service-latest.onrender.com/img2encoding", e.FormData, e.ContentType, des)
+	err := SendRequestWithFormData(faceServiceURL+"/img2encoding", e.FormData, e.ContentType, des)
 	if err != nil {
 		return err
 	}
@@ -36,31 +38,17 @@ func (e *EncodeFace) Execute(des any) error {
 
 func (v *VerifyFace) Execute(des any) error {
 	// Send request to FaceRecognition to verify the face
-	err := SendRequestWithFormData("https://face-reg-service-latest.onrender.com/verify", v.FormData, v.ContentType, des)
+	err := SendRequestWithFormData(faceServiceURL+"/verify", v.FormData, v.ContentType, des)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// SendRequestWithFormData posts the form to the face recognition service. It is sent once, the camera retries
+// on its own and a second attempt after the timeout would keep it waiting twice as long.
 func SendRequestWithFormData(url string, formData *bytes.Buffer, ContentType string, des any) error {
-
-	client := &http.Client{}
-	// Send request to FaceRecognition
-	req, err := http.NewRequest(http.MethodPost, url, formData)
-	if err != nil {
-		return err
-	}
-	// Set the content type
-	req.Header.Set("Content-Type", ContentType)
-	// Send the request
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-
-	// Get the string from the body in the key "face_encoding"
-	body, err := io.ReadAll(resp.Body)
+	body, err := FaceClient.Do(http.MethodPost, url, ContentType, formData.Bytes(), false)
 	if err != nil {
 		return err
 	}
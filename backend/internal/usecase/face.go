@@ -0,0 +1,268 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	"go-jwt/internal/facematch"
+	"go-jwt/internal/imageprep"
+	"math"
+	"mime/multipart"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// a face distance of 0.6 is the default tolerance of the face_recognition library
+	faceMinConfidence     = config.Float("FACE_MATCH_MIN_CONFIDENCE", 0.4)
+	faceMatching          = config.String("FACE_MATCHING", "local")
+	faceDistanceMetric    = config.String("FACE_DISTANCE_METRIC", facematch.MetricEuclidean)
+	faceDistanceTolerance = config.Float("FACE_DISTANCE_TOLERANCE", 0.6)
+)
+
+// CheckFaceConfig fails on a FACE_MATCHING or a FACE_DISTANCE_METRIC that is not known, so the server does not
+// start rather than reject every face
+func CheckFaceConfig() error {
+	if faceMatching != "local" && faceMatching != "remote" {
+		return fmt.Errorf("FACE_MATCHING: unknown matching %q, use \"local\" or \"remote\"", faceMatching)
+	}
+	if err := facematch.CheckMetric(faceDistanceMetric); err != nil {
+		return fmt.Errorf("FACE_DISTANCE_METRIC: %w", err)
+	}
+	return nil
+}
+
+// RecognizeFace compares the face in the image with the faces enrolled in the house of the camera and
+// opens the door of the camera only for a match of at least FACE_MATCH_MIN_CONFIDENCE.
+// Every attempt is written in the activity log, with the person who matched or the rejection.
+// The image is kept as a snapshot of the visitor, linked to the activity log entry.
+func (s *deviceUsecase) RecognizeFace(cameraID int, image []byte, filename string, contentType string) (*entity.FaceMatch, *entity.DeviceCommand, error) {
+	image, _, err := imageprep.Prepare(image, contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	camera, err := s.deviceRepo.GetDeviceByID(cameraID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, entity.ErrCameraNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if camera.Type != "Camera" {
+		return nil, nil, entity.ErrNotACamera
+	}
+	houseID := camera.House_id
+
+	encodings, err := s.deviceRepo.GetFaceEncodings(houseID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	match := &entity.FaceMatch{}
+	if len(encodings) > 0 {
+		if faceMatching == "remote" {
+			match, err = s.matchRemote(houseID, image, filename, encodings)
+		} else {
+			match, err = s.matchLocal(houseID, image, filename, encodings)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if match.Person_id != nil {
+			if person, err := s.deviceRepo.GetFacePerson(houseID, *match.Person_id); err == nil {
+				match.Person_name = person.Name
+			}
+		}
+	}
+
+	// the camera acts for a face it could not put a name on
+	actor := entity.DeviceActor(cameraID, camera.Name)
+	if !match.Matched || match.Confidence < faceMinConfidence {
+		match.Matched = false
+		activityID := s.logFaceEvent(houseID, cameraID, actor, entity.EventFaceRejected,
+			map[string]interface{}{"camera_id": cameraID, "confidence": match.Confidence},
+			fmt.Sprintf("Face rejected by camera %d (confidence %.2f)", cameraID, match.Confidence))
+		s.saveSnapshot(houseID, cameraID, activityID, image, match)
+		return match, nil, nil
+	}
+
+	name := match.Person_name
+	if name == "" {
+		name = "an unnamed face"
+	}
+	if match.Person_id != nil {
+		actor = entity.PersonActor(*match.Person_id, match.Person_name)
+	}
+	activityID := s.logFaceEvent(houseID, cameraID, actor, entity.EventFaceRecognized,
+		map[string]interface{}{"camera_id": cameraID, "person_id": match.Person_id, "confidence": match.Confidence},
+		fmt.Sprintf("Face of %s recognized by camera %d (confidence %.2f)", name, cameraID, match.Confidence))
+	s.saveSnapshot(houseID, cameraID, activityID, image, match)
+	command, err := s.commandService.SendCommand(houseID, s.cameraDoor(houseID, cameraID), "Door", CommandOpen, 0, "Open the door for "+name+" after face verified", actor)
+	if err != nil {
+		return match, nil, err
+	}
+	return match, command, nil
+}
+
+// matchRemote sends the image with every encoding of the house to the face recognition service
+func (s *deviceUsecase) matchRemote(houseID int, image []byte, filename string, encodings []entity.FaceEncoding) (*entity.FaceMatch, error) {
+	formData, writer, err := imageFormData(image, filename)
+	if err != nil {
+		return nil, err
+	}
+	// the service answers in the order of "encoding_array"
+	for _, encoding := range encodings {
+		if err := writer.WriteField("encoding_array", encoding.Face_encoding); err != nil {
+			return nil, err
+		}
+	}
+	writer.Close()
+
+	var data map[string]interface{}
+	if err := s.VerifyFace(houseID, formData, writer.FormDataContentType(), &data); err != nil {
+		return nil, err
+	}
+	return faceMatch(data, encodings)
+}
+
+// matchLocal only asks the face recognition service for the encoding of the image,
+// and compares it with the encodings of the house here
+func (s *deviceUsecase) matchLocal(houseID int, image []byte, filename string, encodings []entity.FaceEncoding) (*entity.FaceMatch, error) {
+	encoding, err := s.encodeFace(houseID, image, filename)
+	if err != nil {
+		return nil, err
+	}
+	probe, err := facematch.ParseEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([][]float64, len(encodings))
+	for i, stored := range encodings {
+		// an encoding that can not be read stays nil and is skipped by Closest
+		if candidates[i], err = facematch.ParseEncoding(stored.Face_encoding); err != nil {
+			fmt.Println("parse face encoding failed:", stored.Encoding_id, err.Error())
+		}
+	}
+
+	match := &entity.FaceMatch{}
+	best, distance := facematch.Closest(faceDistanceMetric, probe, candidates)
+	if best < 0 {
+		return match, nil
+	}
+	match.Confidence = math.Max(0, math.Min(1, 1-distance))
+	if distance <= faceDistanceTolerance {
+		match.Matched = true
+		match.Person_id = encodings[best].Person_id
+	}
+	return match, nil
+}
+
+// EncodeImage checks and prepares the image, then returns the encoding of the face in it
+func (s *deviceUsecase) EncodeImage(houseID int, image []byte, filename string, contentType string) (string, error) {
+	image, _, err := imageprep.Prepare(image, contentType)
+	if err != nil {
+		return "", err
+	}
+	return s.encodeFace(houseID, image, filename)
+}
+
+func (s *deviceUsecase) encodeFace(houseID int, image []byte, filename string) (string, error) {
+	formData, writer, err := imageFormData(image, filename)
+	if err != nil {
+		return "", err
+	}
+	writer.Close()
+
+	var data map[string]interface{}
+	if err := s.EncodeFace(houseID, formData, writer.FormDataContentType(), &data); err != nil {
+		return "", err
+	}
+	if message, ok := data["error"].(string); ok {
+		return "", fmt.Errorf("%w: %s", entity.ErrNoFaceFound, message)
+	}
+	encoding, ok := data["face_encoding"].(string)
+	if !ok {
+		return "", errors.New("invalid or missing 'face_encoding' in the response of the face recognition service")
+	}
+	return encoding, nil
+}
+
+// logFaceEvent writes the event to the activity log and to the audit log. It returns the id of the
+// activity log entry, nil when it could not be written.
+func (s *deviceUsecase) logFaceEvent(houseID int, cameraID int, actor entity.Actor, code string, params map[string]interface{}, event string) *int {
+	activityLog := &entity.ActivityLog{
+		House_id:      houseID,
+		Time:          time.Now(),
+		Device:        "Camera",
+		Device_id:     &cameraID,
+		Type_of_event: event,
+		Actor:         actor,
+		Event_code:    code,
+		Params:        params,
+	}
+	if err := s.auditService.Record(houseID, actor, &cameraID, code, event, params); err != nil {
+		fmt.Println("record audit entry failed:", err.Error())
+	}
+	if err := s.deviceRepo.CreateActivityLog(activityLog); err != nil {
+		fmt.Println("create activity log failed:", err.Error())
+		return nil
+	}
+	return &activityLog.ID
+}
+
+// imageFormData puts the image in the "img" field of a new form for the face provider.
+// The writer is left open so more fields can be added, it has to be closed before sending.
+func imageFormData(image []byte, filename string) (*bytes.Buffer, *multipart.Writer, error) {
+	formData := new(bytes.Buffer)
+	writer := multipart.NewWriter(formData)
+	part, err := writer.CreateFormFile("img", filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := part.Write(image); err != nil {
+		return nil, nil, err
+	}
+	return formData, writer, nil
+}
+
+// faceMatch reads the answer of the face recognition service. The confidence comes from "distances", one face
+// distance per encoding, and "is_match" can overrule it. An answer without distances cannot tell how close the
+// face is, it is never a match.
+func faceMatch(data map[string]interface{}, encodings []entity.FaceEncoding) (*entity.FaceMatch, error) {
+	if message, ok := data["error"].(string); ok {
+		return nil, fmt.Errorf("%w: %s", entity.ErrNoFaceFound, message)
+	}
+
+	match := &entity.FaceMatch{}
+	isMatch, hasIsMatch := data["is_match"].(bool)
+	best := -1
+
+	if distances, ok := data["distances"].([]interface{}); ok && len(distances) == len(encodings) {
+		bestDistance := math.Inf(1)
+		for i, value := range distances {
+			if distance, ok := value.(float64); ok && distance < bestDistance {
+				best, bestDistance = i, distance
+			}
+		}
+		if best >= 0 {
+			match.Confidence = math.Max(0, math.Min(1, 1-bestDistance))
+			match.Matched = !hasIsMatch || isMatch
+		}
+	} else if hasIsMatch || data["matches"] != nil {
+		if isMatch {
+			fmt.Println("face match rejected: the face recognition service sent no distances")
+		}
+	} else {
+		return nil, errors.New("something wrong with the response of the face recognition service")
+	}
+
+	if match.Matched && best >= 0 {
+		match.Person_id = encodings[best].Person_id
+	}
+	return match, nil
+}
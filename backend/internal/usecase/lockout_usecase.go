@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	"go-jwt/internal/ratelimit"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func NewLockoutUsecase(userRepo repository.UserRepository, deviceRepo repository.DeviceRepository, lockoutRepo repository.LockoutRepository) LockoutUsecase {
+	return &lockoutUsecase{
+		userRepo:    userRepo,
+		deviceRepo:  deviceRepo,
+		lockoutRepo: lockoutRepo,
+		login: ratelimit.New(ratelimit.Policy{
+			Limit:     config.Int("LOGIN_RATE_LIMIT", 10),
+			Window:    config.Duration("LOGIN_RATE_WINDOW", time.Minute),
+			Threshold: config.Int("LOGIN_LOCKOUT_THRESHOLD", 5),
+			LockFor:   config.Duration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		}, lockoutRepo),
+		face: ratelimit.New(ratelimit.Policy{
+			Limit:     config.Int("FACE_RATE_LIMIT", 20),
+			Window:    config.Duration("FACE_RATE_WINDOW", time.Minute),
+			Threshold: config.Int("FACE_LOCKOUT_THRESHOLD", 5),
+			LockFor:   config.Duration("FACE_LOCKOUT_DURATION", 5*time.Minute),
+		}, lockoutRepo),
+	}
+}
+
+// LockoutUsecase throttles the logins and the face verifications. The attempts are counted per username and
+// per IP for the logins, per camera and per IP for the faces. A key is locked out after too many consecutive
+// failures, and the owners of the house are notified. A stranger at a camera only locks that camera, the other
+// cameras of the house keep working. The failures and the lockouts are in the database, the rate of the
+// attempts is counted by each instance.
+type LockoutUsecase interface {
+	CheckLogin(username string, ip string) error
+	LoginFailed(username string, ip string)
+	LoginSucceeded(username string, ip string)
+	CheckFace(cameraID int, ip string) error
+	FaceFailed(cameraID int, ip string)
+	FaceSucceeded(cameraID int, ip string)
+	GetLockouts() ([]ratelimit.Lockout, error)
+	ClearLockout(key string) error
+	Prune(now time.Time)
+}
+
+type lockoutUsecase struct {
+	userRepo    repository.UserRepository
+	deviceRepo  repository.DeviceRepository
+	lockoutRepo repository.LockoutRepository
+	login       *ratelimit.Limiter
+	face        *ratelimit.Limiter
+}
+
+func loginKeys(username string, ip string) []string {
+	return []string{"login:user:" + strings.ToLower(username), "login:ip:" + ip}
+}
+
+func (s *lockoutUsecase) CheckLogin(username string, ip string) error {
+	if wait, ok := s.login.Allow(time.Now(), loginKeys(username, ip)...); !ok {
+		return &entity.RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (s *lockoutUsecase) LoginFailed(username string, ip string) {
+	for _, key := range s.login.Failure(time.Now(), loginKeys(username, ip)...) {
+		fmt.Println("lockout:", key)
+		if !strings.HasPrefix(key, "login:user:") {
+			continue
+		}
+		user, err := s.userRepo.GetUserByUsername(username)
+		if err != nil {
+			continue
+		}
+		houseIDs, err := s.userRepo.GetHouseID(user.ID)
+		if err != nil {
+			fmt.Println("get house id failed:", err.Error())
+			continue
+		}
+		for _, houseID := range houseIDs {
+			s.notify(user.ID, houseID, "Account locked", "Too many failed logins to your account, the last one from "+ip)
+		}
+	}
+}
+
+func (s *lockoutUsecase) LoginSucceeded(username string, ip string) {
+	s.login.Success(loginKeys(username, ip)...)
+}
+
+func faceKeys(cameraID int, ip string) []string {
+	return []string{"face:camera:" + strconv.Itoa(cameraID), "face:ip:" + ip}
+}
+
+func (s *lockoutUsecase) CheckFace(cameraID int, ip string) error {
+	keys := faceKeys(cameraID, ip)
+	if wait, ok := s.face.Allow(time.Now(), keys...); !ok {
+		return &entity.RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (s *lockoutUsecase) FaceFailed(cameraID int, ip string) {
+	locked := s.face.Failure(time.Now(), faceKeys(cameraID, ip)...)
+	if len(locked) == 0 {
+		return
+	}
+	fmt.Println("lockout:", strings.Join(locked, ", "))
+	camera, err := s.deviceRepo.GetDeviceByID(cameraID)
+	if err != nil {
+		return
+	}
+	houseID := camera.House_id
+
+	ownerIDs, err := s.userRepo.GetOwnerIDs(houseID)
+	if err != nil {
+		fmt.Println("get owner ids failed:", err.Error())
+		return
+	}
+	for _, userID := range ownerIDs {
+		s.notify(userID, houseID, "Face verification locked",
+			fmt.Sprintf("Too many unknown faces at camera %d, face verification is locked for a while", cameraID))
+	}
+}
+
+func (s *lockoutUsecase) FaceSucceeded(cameraID int, ip string) {
+	s.face.Success(faceKeys(cameraID, ip)...)
+}
+
+// GetLockouts returns the login and the face lockouts of every instance
+func (s *lockoutUsecase) GetLockouts() ([]ratelimit.Lockout, error) {
+	return s.lockoutRepo.Lockouts(time.Now())
+}
+
+// ClearLockout forgets the attempts and the failures of a key like "login:user:thien" or "face:camera:3"
+func (s *lockoutUsecase) ClearLockout(key string) error {
+	limiter := s.face
+	if strings.HasPrefix(key, "login:") {
+		limiter = s.login
+	}
+	cleared, err := limiter.Clear(key)
+	if err != nil {
+		return err
+	}
+	if !cleared {
+		return entity.ErrLockoutNotFound
+	}
+	return nil
+}
+
+func (s *lockoutUsecase) Prune(now time.Time) {
+	s.login.Prune(now)
+	s.face.Prune(now)
+}
+
+func (s *lockoutUsecase) notify(userID int, houseID int, title string, description string) {
+	err := s.userRepo.CreateNotification(userID, houseID, &entity.Notification{
+		Time:        time.Now(),
+		Title:       title,
+		Description: description,
+	})
+	if err != nil {
+		fmt.Println("create notification failed:", err.Error())
+	}
+}
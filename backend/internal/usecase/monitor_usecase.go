@@ -0,0 +1,164 @@
+package usecase
+
+import (
+	"fmt"
+	"go-jwt/internal/anomaly"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	"sync"
+	"time"
+)
+
+var (
+	deviceOfflineAfter = config.Duration("DEVICE_OFFLINE_AFTER", 10*time.Minute)
+	// a malfunction is notified once per sensor in this time, a broken sensor sends many bad readings
+	anomalyNotifyInterval = config.Duration("ANOMALY_NOTIFY_INTERVAL", time.Hour)
+	anomalyNotify         = config.Bool("ANOMALY_NOTIFY", true)
+)
+
+// the last seen time of an online device is only written again after this long, not on every request
+const seenResolution = 30 * time.Second
+
+func NewMonitorUsecase(deviceRepo repository.DeviceRepository, userRepo repository.UserRepository, deviceTypes DeviceTypeUsecase) MonitorUsecase {
+	return &monitorUsecase{
+		deviceRepo:  deviceRepo,
+		userRepo:    userRepo,
+		deviceTypes: deviceTypes,
+		detector: anomaly.New(anomaly.Policy{
+			Window:     config.Int("ANOMALY_WINDOW", 30),
+			MinSamples: config.Int("ANOMALY_MIN_SAMPLES", 10),
+			ZScore:     config.Float("ANOMALY_Z_SCORE", 4),
+			MinStd:     config.Float("ANOMALY_MIN_STDDEV", 0.5),
+			Flatline:   config.Int("ANOMALY_FLATLINE", 100),
+			Accept:     config.Int("ANOMALY_ACCEPT", 5),
+		}),
+		notified: make(map[int]time.Time),
+	}
+}
+
+// MonitorUsecase follows the health of the devices. A device is online from its first request, and
+// offline when it sends nothing for the Offline_after of its type. The owners are notified of every change.
+// The readings of the sensors go through an anomaly detector, a suspicious reading is a sensor malfunction.
+type MonitorUsecase interface {
+	Seen(device *entity.Device, now time.Time)
+	CheckOffline(now time.Time)
+	CheckReadings(device *entity.Device, values []float64) ([]anomaly.Result, func())
+}
+
+type monitorUsecase struct {
+	deviceRepo  repository.DeviceRepository
+	userRepo    repository.UserRepository
+	deviceTypes DeviceTypeUsecase
+	detector    *anomaly.Detector
+
+	mu       sync.Mutex
+	notified map[int]time.Time // the last malfunction notification of each device
+}
+
+func (s *monitorUsecase) Seen(device *entity.Device, now time.Time) {
+	if device.Online && device.Last_seen != nil && now.Sub(*device.Last_seen) < seenResolution {
+		return
+	}
+	// a device seen for the first time was never offline
+	backOnline := !device.Online && device.Last_seen != nil
+
+	if err := s.deviceRepo.MarkSeen(device.ID, now); err != nil {
+		fmt.Println("mark device seen failed:", err.Error())
+		return
+	}
+	device.Last_seen, device.Online = &now, true
+
+	if backOnline {
+		s.notify(device, "Device back online", fmt.Sprintf("%s (%s) sends data again", device.Name, device.Type))
+	}
+}
+
+// CheckOffline marks offline the online devices that sent nothing for too long
+func (s *monitorUsecase) CheckOffline(now time.Time) {
+	devices, err := s.deviceRepo.GetOnlineDevices()
+	if err != nil {
+		fmt.Println("get online devices failed:", err.Error())
+		return
+	}
+
+	for i := range devices {
+		device := &devices[i]
+		timeout := s.offlineAfter(device.Type)
+		if timeout <= 0 || device.Last_seen == nil || now.Sub(*device.Last_seen) < timeout {
+			continue
+		}
+		offline, err := s.deviceRepo.MarkOffline(device.ID, now.Add(-timeout))
+		if err != nil {
+			fmt.Println("mark device offline failed:", err.Error())
+			continue
+		}
+		if offline {
+			s.notify(device, "Device offline", fmt.Sprintf("%s (%s) sent nothing since %s",
+				device.Name, device.Type, device.Last_seen.Format("2006-01-02 15:04")))
+		}
+	}
+}
+
+// CheckReadings only checks the sensors, the devices that take commands change their value on purpose.
+// The readings are checked in order, stored must be called once they are stored: the detector learns them
+// and the owners are notified of a malfunction only then.
+func (s *monitorUsecase) CheckReadings(device *entity.Device, values []float64) ([]anomaly.Result, func()) {
+	schema, err := s.deviceTypes.GetDeviceType(device.Type)
+	if err != nil || len(schema.Commands) > 0 || (schema.Value_kind != entity.ValueNumber && schema.Value_kind != entity.ValueInteger) {
+		return make([]anomaly.Result, len(values)), func() {}
+	}
+
+	pending := s.detector.Check(device.ID, values...)
+	stored := func() {
+		s.detector.Commit(pending)
+		for _, result := range pending.Results {
+			if result.Anomaly {
+				s.malfunction(device, result)
+				return
+			}
+		}
+	}
+	return pending.Results, stored
+}
+
+// malfunction notifies the owners at most once per ANOMALY_NOTIFY_INTERVAL for the device
+func (s *monitorUsecase) malfunction(device *entity.Device, result anomaly.Result) {
+	now := time.Now()
+	s.mu.Lock()
+	last, ok := s.notified[device.ID]
+	notify := anomalyNotify && (!ok || now.Sub(last) >= anomalyNotifyInterval)
+	if notify {
+		s.notified[device.ID] = now
+	}
+	s.mu.Unlock()
+	if notify {
+		s.notify(device, "Sensor malfunction", fmt.Sprintf("%s (%s) sends suspicious readings: %s", device.Name, device.Type, result.Detail))
+	}
+}
+
+func (s *monitorUsecase) offlineAfter(deviceType string) time.Duration {
+	schema, err := s.deviceTypes.GetDeviceType(deviceType)
+	if err != nil || schema.Offline_after == nil {
+		return deviceOfflineAfter
+	}
+	return time.Duration(*schema.Offline_after) * time.Second
+}
+
+func (s *monitorUsecase) notify(device *entity.Device, title string, description string) {
+	ownerIDs, err := s.userRepo.GetOwnerIDs(device.House_id)
+	if err != nil {
+		fmt.Println("get owner ids failed:", err.Error())
+		return
+	}
+	for _, userID := range ownerIDs {
+		err := s.userRepo.CreateNotification(userID, device.House_id, &entity.Notification{
+			Time:        time.Now(),
+			Title:       title,
+			Description: description,
+		})
+		if err != nil {
+			fmt.Println("create notification failed:", err.Error())
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	entity "go-jwt/internal/entity"
+	"time"
+)
+
+// The read state of a notification is kept per recipient, marking it read for a user leaves it unread
+// for the other owners of the house.
+
+// GetNotifications returns the notifications of the user, the newest first
+func (s *userUsecase) GetNotifications(userID int, filter entity.NotificationFilter) ([]entity.Notification, error) {
+	return s.userRepo.GetNotifications(userID, filter)
+}
+
+// CountUnreadNotifications leaves the archived notifications out, houseID nil counts every house
+func (s *userUsecase) CountUnreadNotifications(userID int, houseID *int) (int64, error) {
+	return s.userRepo.CountUnreadNotifications(userID, houseID)
+}
+
+// MarkNotificationRead marks the notification read, or unread again when read is false
+func (s *userUsecase) MarkNotificationRead(userID int, notificationID int, read bool) error {
+	return s.userRepo.MarkNotificationRead(userID, notificationID, read, time.Now())
+}
+
+// MarkAllNotificationsRead returns how many notifications were marked read, houseID nil for every house
+func (s *userUsecase) MarkAllNotificationsRead(userID int, houseID *int) (int64, error) {
+	return s.userRepo.MarkAllNotificationsRead(userID, houseID, time.Now())
+}
+
+// ArchiveNotification hides the notification from the list and from the unread count, it is still listed
+// with ?archived=true
+func (s *userUsecase) ArchiveNotification(userID int, notificationID int) error {
+	return s.userRepo.ArchiveNotification(userID, notificationID, time.Now())
+}
+
+// DeleteNotification removes the notification for the user only, the other recipients keep it
+func (s *userUsecase) DeleteNotification(userID int, notificationID int) error {
+	return s.userRepo.DeleteNotification(userID, notificationID)
+}
@@ -0,0 +1,200 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	"strings"
+	"time"
+)
+
+func NewRoomUsecase(roomRepo repository.RoomRepository, commandService CommandUsecase, deviceTypes DeviceTypeUsecase) RoomUsecase {
+	return &roomUsecase{
+		roomRepo:       roomRepo,
+		commandService: commandService,
+		deviceTypes:    deviceTypes,
+	}
+}
+
+type RoomUsecase interface {
+	CreateRoom(room *entity.Room) error
+	GetRooms(houseID int) ([]entity.RoomSummary, error)
+	GetRoom(houseID int, roomID int) (*entity.RoomSummary, error)
+	RenameRoom(room *entity.Room) error
+	DeleteRoom(houseID int, roomID int) error
+	SetDeviceRoom(houseID int, deviceID int, roomID *int) error
+	SendRoomCommand(houseID int, roomID int, command string, value float64, actor entity.Actor) ([]entity.SetResult, error)
+}
+
+type roomUsecase struct {
+	roomRepo       repository.RoomRepository
+	commandService CommandUsecase
+	deviceTypes    DeviceTypeUsecase
+}
+
+func (s *roomUsecase) CreateRoom(room *entity.Room) error {
+	if err := validateRoom(room); err != nil {
+		return err
+	}
+	room.Created_at = time.Now()
+	return s.roomRepo.CreateRoom(room)
+}
+
+// GetRooms returns every room of the house with the state of its devices
+func (s *roomUsecase) GetRooms(houseID int) ([]entity.RoomSummary, error) {
+	rooms, err := s.roomRepo.GetRooms(houseID)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.roomRepo.GetDevices(houseID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]entity.RoomSummary, len(rooms))
+	index := make(map[int]int, len(rooms))
+	roomDevices := make([][]entity.Device, len(rooms))
+	for i, room := range rooms {
+		index[room.ID] = i
+	}
+	for _, device := range devices {
+		if device.Room_id == nil {
+			continue
+		}
+		if i, ok := index[*device.Room_id]; ok {
+			roomDevices[i] = append(roomDevices[i], device)
+		}
+	}
+	for i, room := range rooms {
+		summaries[i] = s.summary(room, roomDevices[i])
+	}
+	return summaries, nil
+}
+
+// GetRoom returns the room with its devices
+func (s *roomUsecase) GetRoom(houseID int, roomID int) (*entity.RoomSummary, error) {
+	room, err := s.roomRepo.GetRoom(houseID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.roomRepo.GetDevices(houseID, &roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := s.summary(*room, devices)
+	summary.Device_list = devices
+	return &summary, nil
+}
+
+// summary counts the devices of the room and averages the data of its sensors by type
+func (s *roomUsecase) summary(room entity.Room, devices []entity.Device) entity.RoomSummary {
+	summary := entity.RoomSummary{
+		Room:       room,
+		Devices:    len(devices),
+		Devices_on: []string{},
+		Averages:   map[string]*float64{},
+	}
+	counts := map[string]int{}
+	for i := range devices {
+		device := &devices[i]
+		if !device.Online && device.Last_seen != nil {
+			summary.Offline_devices++
+		}
+		schema, err := s.deviceTypes.GetDeviceType(device.Type)
+		if err != nil {
+			continue
+		}
+		switch {
+		case isSensor(schema):
+			if summary.Averages[device.Type] == nil {
+				summary.Averages[device.Type] = new(float64)
+			}
+			*summary.Averages[device.Type] += device.Data
+			counts[device.Type]++
+		case isOn(schema, device):
+			summary.Devices_on = append(summary.Devices_on, device.Name)
+		}
+	}
+	for deviceType, sum := range summary.Averages {
+		*sum /= float64(counts[deviceType])
+	}
+	return summary
+}
+
+func (s *roomUsecase) RenameRoom(room *entity.Room) error {
+	if err := validateRoom(room); err != nil {
+		return err
+	}
+	return s.roomRepo.RenameRoom(room)
+}
+
+// DeleteRoom keeps the devices of the room, they are in no room after
+func (s *roomUsecase) DeleteRoom(houseID int, roomID int) error {
+	return s.roomRepo.DeleteRoom(houseID, roomID)
+}
+
+// SetDeviceRoom moves a device of the house to one of its rooms, or out of any room when roomID is nil
+func (s *roomUsecase) SetDeviceRoom(houseID int, deviceID int, roomID *int) error {
+	if roomID != nil {
+		if _, err := s.roomRepo.GetRoom(houseID, *roomID); err != nil {
+			return err
+		}
+	}
+	return s.roomRepo.SetDeviceRoom(houseID, deviceID, roomID)
+}
+
+// SendRoomCommand sends the command to every device of the room that takes it, e.g. "off" turns off the lights
+// and the fans and leaves the sensors alone. A device that fails does not stop the others.
+func (s *roomUsecase) SendRoomCommand(houseID int, roomID int, command string, value float64, actor entity.Actor) ([]entity.SetResult, error) {
+	room, err := s.roomRepo.GetRoom(houseID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.roomRepo.GetDevices(houseID, &roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []entity.SetResult{}
+	for _, device := range devices {
+		schema, err := s.deviceTypes.GetDeviceType(device.Type)
+		if err != nil || !hasCommand(schema, command) {
+			continue
+		}
+
+		result := entity.SetResult{
+			Device_id:   device.ID,
+			Device_name: device.Name,
+			Device_type: device.Type,
+			Success:     true,
+		}
+		description := fmt.Sprintf("Send %s to %s in the room %s", command, device.Name, room.Name)
+		sent, err := s.commandService.SendCommand(houseID, device.ID, device.Type, command, value, description, actor)
+		if err == nil {
+			result.Command_ids = []int{sent.ID}
+			if sent.Status != entity.CommandSent {
+				err = errors.New(sent.Last_error)
+			}
+		}
+		if err != nil {
+			fmt.Println("send room command failed:", err.Error())
+			result.Success = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: no device of the room %s takes %q", entity.ErrInvalidCommand, room.Name, command)
+	}
+	return results, nil
+}
+
+func validateRoom(room *entity.Room) error {
+	room.Name = strings.TrimSpace(room.Name)
+	if room.Name == "" || len(room.Name) > 50 {
+		return fmt.Errorf("%w: name must be between 1 and 50 characters", entity.ErrInvalidRoom)
+	}
+	return nil
+}
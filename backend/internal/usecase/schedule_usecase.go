@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	"go-jwt/internal/scheduler"
+	"time"
+)
+
+// a run that is late by more than this was missed (the server was down), it only runs if the schedule catches up
+var scheduleGracePeriod = config.Duration("SCHEDULER_GRACE_PERIOD", 2*time.Minute)
+
+func NewScheduleUsecase(scheduleRepo repository.ScheduleRepository, userRepo repository.UserRepository, commandService CommandUsecase) ScheduleUsecase {
+	return &scheduleUsecase{
+		scheduleRepo:   scheduleRepo,
+		userRepo:       userRepo,
+		commandService: commandService,
+	}
+}
+
+type ScheduleUsecase interface {
+	SetHouseLocation(houseID int, latitude float64, longitude float64) error
+	CreateSchedule(schedule *entity.Schedule) error
+	GetSchedulesByHouseID(houseID int) ([]entity.Schedule, error)
+	GetScheduleByID(houseID int, scheduleID int) (*entity.Schedule, error)
+	UpdateSchedule(schedule *entity.Schedule) error
+	DeleteSchedule(houseID int, scheduleID int) error
+	RunDueSchedules(now time.Time)
+}
+
+type scheduleUsecase struct {
+	scheduleRepo   repository.ScheduleRepository
+	userRepo       repository.UserRepository
+	commandService CommandUsecase
+}
+
+func (s *scheduleUsecase) SetHouseLocation(houseID int, latitude float64, longitude float64) error {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("%w: latitude must be in [-90, 90] and longitude in [-180, 180]", entity.ErrInvalidSchedule)
+	}
+	if err := s.scheduleRepo.UpdateHouseLocation(houseID, latitude, longitude); err != nil {
+		return err
+	}
+
+	// sunrise and sunset moved with the house
+	schedules, err := s.scheduleRepo.GetSchedulesByHouseID(houseID)
+	if err != nil {
+		return err
+	}
+	for i := range schedules {
+		if schedules[i].Kind != entity.ScheduleKindSun {
+			continue
+		}
+		if err := s.prepareSchedule(&schedules[i]); err != nil {
+			return err
+		}
+		if err := s.scheduleRepo.UpdateSchedule(&schedules[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scheduleUsecase) CreateSchedule(schedule *entity.Schedule) error {
+	if err := s.prepareSchedule(schedule); err != nil {
+		return err
+	}
+	return s.scheduleRepo.CreateSchedule(schedule)
+}
+
+func (s *scheduleUsecase) GetSchedulesByHouseID(houseID int) ([]entity.Schedule, error) {
+	return s.scheduleRepo.GetSchedulesByHouseID(houseID)
+}
+
+func (s *scheduleUsecase) GetScheduleByID(houseID int, scheduleID int) (*entity.Schedule, error) {
+	return s.scheduleRepo.GetScheduleByID(houseID, scheduleID)
+}
+
+func (s *scheduleUsecase) UpdateSchedule(schedule *entity.Schedule) error {
+	old, err := s.scheduleRepo.GetScheduleByID(schedule.House_id, schedule.ID)
+	if err != nil {
+		return err
+	}
+	// the run history is kept by the server
+	schedule.Last_run = old.Last_run
+	schedule.Last_status = old.Last_status
+
+	if err := s.prepareSchedule(schedule); err != nil {
+		return err
+	}
+	return s.scheduleRepo.UpdateSchedule(schedule)
+}
+
+func (s *scheduleUsecase) DeleteSchedule(houseID int, scheduleID int) error {
+	return s.scheduleRepo.DeleteSchedule(houseID, scheduleID)
+}
+
+// prepareSchedule validates the schedule and computes its next run from now
+func (s *scheduleUsecase) prepareSchedule(schedule *entity.Schedule) error {
+	switch schedule.Action {
+	case entity.ScheduleActionDevice:
+		if err := s.commandService.ValidateCommand(schedule.Device_type, schedule.Command, schedule.Value); err != nil {
+			return fmt.Errorf("%w: %s", entity.ErrInvalidSchedule, err.Error())
+		}
+	case entity.ScheduleActionScene:
+		if schedule.Setting_name == "" {
+			return fmt.Errorf("%w: setting_name is required", entity.ErrInvalidSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: action must be device or scene", entity.ErrInvalidSchedule)
+	}
+
+	next, err := s.nextRun(schedule, time.Now())
+	if err != nil {
+		return err
+	}
+	schedule.Next_run = next
+	return nil
+}
+
+func (s *scheduleUsecase) nextRun(schedule *entity.Schedule, after time.Time) (*time.Time, error) {
+	var house *entity.House
+	if schedule.Kind == entity.ScheduleKindSun {
+		h, err := s.scheduleRepo.GetHouseByID(schedule.House_id)
+		if err != nil {
+			return nil, err
+		}
+		house = h
+	}
+
+	next, err := scheduler.NextRun(schedule, after, house)
+	if err != nil {
+		return nil, err
+	}
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+func (s *scheduleUsecase) RunDueSchedules(now time.Time) {
+	schedules, err := s.scheduleRepo.GetDueSchedules(now)
+	if err != nil {
+		fmt.Println("get due schedules failed:", err.Error())
+		return
+	}
+	for i := range schedules {
+		s.runSchedule(&schedules[i], now)
+	}
+}
+
+func (s *scheduleUsecase) runSchedule(schedule *entity.Schedule, now time.Time) {
+	lastRun := schedule.Last_run
+	status := "skipped: missed while the server was down"
+
+	missed := schedule.Next_run != nil && now.Sub(*schedule.Next_run) > scheduleGracePeriod
+	if !missed || schedule.Catch_up {
+		lastRun = &now
+		status = "ok"
+		if err := s.executeSchedule(schedule); err != nil {
+			fmt.Println("run schedule failed:", err.Error())
+			status = "failed: " + err.Error()
+		}
+
+		_ = s.scheduleRepo.CreateActivityLog(&entity.ActivityLog{
+			House_id:      schedule.House_id,
+			Device:        schedule.Device_type,
+			Time:          now,
+			Type_of_event: "Run the schedule " + schedule.Name + " (" + status + ")",
+			Actor:         entity.ScheduleActor(schedule),
+			Event_code:    entity.EventScheduleRun,
+			Params:        map[string]interface{}{"schedule_id": schedule.ID, "status": status},
+		})
+	}
+
+	// an error here leaves the schedule without a next run, it is disabled instead of retried every tick
+	next, err := s.nextRun(schedule, now)
+	if err != nil {
+		status = "disabled: " + err.Error()
+	}
+	enabled := schedule.Enabled && next != nil
+
+	if err := s.scheduleRepo.UpdateScheduleRun(schedule.ID, lastRun, next, status, enabled); err != nil {
+		fmt.Println("update schedule run failed:", err.Error())
+	}
+}
+
+func (s *scheduleUsecase) executeSchedule(schedule *entity.Schedule) error {
+	switch schedule.Action {
+	case entity.ScheduleActionDevice:
+		command, err := s.commandService.SendCommand(schedule.House_id, 0, schedule.Device_type, schedule.Command, schedule.Value, "", entity.ScheduleActor(schedule))
+		if err != nil {
+			return err
+		}
+		if command.Status != entity.CommandSent {
+			return errors.New(command.Last_error)
+		}
+		return nil
+	case entity.ScheduleActionScene:
+		results, err := activateHouseSetting(s.userRepo, s.commandService, schedule.House_id, schedule.Setting_name, entity.ScheduleActor(schedule))
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if !result.Success {
+				return fmt.Errorf("device %d: %s", result.Device_id, result.Error)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: action must be device or scene", entity.ErrInvalidSchedule)
+}
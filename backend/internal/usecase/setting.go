@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	entity "go-jwt/internal/entity"
+	repository "go-jwt/internal/infrastructure/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// activateHouseSetting selects the setting and pushes each of its Set rows to the device.
+// A device that fails does not stop the others, the result of every device is returned.
+func activateHouseSetting(userRepo repository.UserRepository, commands CommandUsecase, houseID int, settingName string, actor entity.Actor) ([]entity.SetResult, error) {
+	if err := userRepo.SelectHouseSetting(houseID, settingName); err != nil {
+		return nil, err
+	}
+
+	sets, err := userRepo.GetSetOfHouseSetting(houseID, settingName)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := userRepo.GetDevicesByHouseID(houseID)
+	if err != nil {
+		return nil, err
+	}
+	deviceTypes := make(map[int]string, len(devices))
+	for _, device := range devices {
+		deviceTypes[device.ID] = device.Type
+	}
+
+	results := make([]entity.SetResult, 0, len(sets))
+	failed := 0
+	for _, set := range sets {
+		result := entity.SetResult{
+			Device_id:   set.Device_id,
+			Device_name: set.Device_name,
+			Device_type: deviceTypes[set.Device_id],
+			Success:     true,
+		}
+
+		// a Set whose device was removed, or changed to a type that takes no command, has nothing to send
+		specs, err := setCommands(commands, result.Device_type, set.Device_data, set.Device_state)
+		if err == nil && len(specs) == 0 {
+			result.Skipped = true
+			if result.Device_type == "" {
+				err = fmt.Errorf("device %d is not a device of the house anymore", set.Device_id)
+			} else {
+				err = fmt.Errorf("device %d is a %s and cannot be set", set.Device_id, result.Device_type)
+			}
+		}
+		for _, spec := range specs {
+			if err != nil {
+				break
+			}
+			var command *entity.DeviceCommand
+			command, err = commands.SendCommand(houseID, set.Device_id, result.Device_type, spec.command, spec.value, "", actor)
+			if err != nil {
+				break
+			}
+			result.Command_ids = append(result.Command_ids, command.ID)
+			// a command that is not sent yet is retried by the outbox, but the device is not set for now
+			if command.Status != entity.CommandSent {
+				err = errors.New(command.Last_error)
+			}
+		}
+		if err != nil {
+			fmt.Println("activate set failed:", err.Error())
+			result.Success = false
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	_ = userRepo.CreateActivityLog(&entity.ActivityLog{
+		House_id:      houseID,
+		Device:        "Setting",
+		Time:          time.Now(),
+		Type_of_event: "Activate the setting " + settingName + " (" + strconv.Itoa(len(sets)-failed) + "/" + strconv.Itoa(len(sets)) + " devices applied)",
+		Actor:         actor,
+		Event_code:    entity.EventSettingActivated,
+		Params:        map[string]interface{}{"setting": settingName, "applied": len(sets) - failed, "total": len(sets)},
+	})
+
+	return results, nil
+}
+
+// validateSets checks the sets against the devices of the house and fills their house and setting name
+func validateSets(userRepo repository.UserRepository, commands CommandUsecase, houseID int, settingName string, sets []entity.Set) error {
+	devices, err := userRepo.GetDevicesByHouseID(houseID)
+	if err != nil {
+		return err
+	}
+	deviceByID := make(map[int]entity.Device, len(devices))
+	for _, device := range devices {
+		deviceByID[device.ID] = device
+	}
+
+	seen := make(map[int]bool, len(sets))
+	for i := range sets {
+		device, ok := deviceByID[sets[i].Device_id]
+		if !ok {
+			return fmt.Errorf("%w: device %d is not a device of house %d", entity.ErrInvalidHouseSetting, sets[i].Device_id, houseID)
+		}
+		if seen[device.ID] {
+			return fmt.Errorf("%w: device %d is set twice", entity.ErrInvalidHouseSetting, device.ID)
+		}
+		seen[device.ID] = true
+
+		specs, err := setCommands(commands, device.Type, sets[i].Device_data, sets[i].Device_state)
+		if err != nil {
+			return fmt.Errorf("%w: device %d: %s", entity.ErrInvalidHouseSetting, device.ID, err.Error())
+		}
+		if len(specs) == 0 {
+			return fmt.Errorf("%w: device %d is a %s and cannot be set", entity.ErrInvalidHouseSetting, device.ID, device.Type)
+		}
+
+		sets[i].House_id = houseID
+		sets[i].Name = settingName
+		sets[i].Device_name = device.Name
+	}
+	return nil
+}
+
+func validateSettingName(settingName string) error {
+	if strings.TrimSpace(settingName) == "" || len(settingName) > 50 {
+		return fmt.Errorf("%w: name must be between 1 and 50 characters", entity.ErrInvalidHouseSetting)
+	}
+	return nil
+}
+
+func createHouseSetting(userRepo repository.UserRepository, commands CommandUsecase, houseID int, settingName string, sets []entity.Set) error {
+	if err := validateSettingName(settingName); err != nil {
+		return err
+	}
+	if err := validateSets(userRepo, commands, houseID, settingName, sets); err != nil {
+		return err
+	}
+	return userRepo.CreateHouseSetting(&entity.HouseSetting{Name: settingName, House_id: houseID}, sets)
+}
+
+// snapshotHouseSetting creates a setting from the current data and state of every device of the house that can be set
+func snapshotHouseSetting(userRepo repository.UserRepository, commands CommandUsecase, houseID int, settingName string) ([]entity.Set, error) {
+	devices, err := userRepo.GetDevicesByHouseID(houseID)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := []entity.Set{}
+	for _, device := range devices {
+		state := device.Data > 0
+		record, err := userRepo.GetLatestDataRecord(device.ID)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			state = record.Device_state
+		}
+
+		// sensors and out of range data are left out of the snapshot
+		specs, err := setCommands(commands, device.Type, device.Data, state)
+		if err != nil || len(specs) == 0 {
+			continue
+		}
+		sets = append(sets, entity.Set{Device_id: device.ID, Device_data: device.Data, Device_state: state})
+	}
+
+	if err := createHouseSetting(userRepo, commands, houseID, settingName, sets); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+func cloneHouseSetting(userRepo repository.UserRepository, commands CommandUsecase, houseID int, settingName string, newName string) ([]entity.Set, error) {
+	settings, err := userRepo.GetHouseSettingByHouseID(houseID)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, setting := range settings {
+		found = found || setting.Name == settingName
+	}
+	if !found {
+		return nil, entity.ErrHouseSettingNotFound
+	}
+
+	sets, err := userRepo.GetSetOfHouseSetting(houseID, settingName)
+	if err != nil {
+		return nil, err
+	}
+	if err := createHouseSetting(userRepo, commands, houseID, newName, sets); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	"net/http"
+	"time"
+)
+
+var snapshotRetention = config.Duration("SNAPSHOT_RETENTION", 30*24*time.Hour)
+
+// saveSnapshot keeps the image of a face verification. A snapshot that can not be saved is only logged,
+// it must not keep the door closed.
+func (s *deviceUsecase) saveSnapshot(houseID int, cameraID int, activityID *int, image []byte, match *entity.FaceMatch) {
+	now := time.Now()
+	contentType := http.DetectContentType(image)
+	snapshot := &entity.Snapshot{
+		House_id:     houseID,
+		Camera_id:    cameraID,
+		Activity_id:  activityID,
+		Blob_key:     fmt.Sprintf("snapshots/%d/%d-%d", houseID, cameraID, now.UnixNano()),
+		Content_type: contentType,
+		Size:         len(image),
+		Matched:      match.Matched,
+		Person_id:    match.Person_id,
+		Confidence:   match.Confidence,
+		Created_at:   now,
+	}
+
+	if err := s.blobStore.Put(snapshot.Blob_key, image); err != nil {
+		fmt.Println("save snapshot failed:", err.Error())
+		return
+	}
+	if err := s.deviceRepo.CreateSnapshot(snapshot); err != nil {
+		fmt.Println("create snapshot failed:", err.Error())
+		if err := s.blobStore.Delete(snapshot.Blob_key); err != nil {
+			fmt.Println("delete snapshot failed:", err.Error())
+		}
+	}
+}
+
+func (s *deviceUsecase) GetSnapshots(houseID int, limit int) ([]entity.Snapshot, error) {
+	return s.deviceRepo.GetSnapshots(houseID, limit)
+}
+
+func (s *deviceUsecase) GetSnapshotImage(houseID int, snapshotID int) (*entity.Snapshot, []byte, error) {
+	snapshot, err := s.deviceRepo.GetSnapshot(houseID, snapshotID)
+	if err != nil {
+		return nil, nil, err
+	}
+	image, err := s.blobStore.Get(snapshot.Blob_key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return snapshot, image, nil
+}
+
+// PruneSnapshots deletes the snapshots older than SNAPSHOT_RETENTION, a batch at a time
+func (s *deviceUsecase) PruneSnapshots(now time.Time) {
+	snapshots, err := s.deviceRepo.GetSnapshotsBefore(now.Add(-snapshotRetention), 500)
+	if err != nil {
+		fmt.Println("get old snapshots failed:", err.Error())
+		return
+	}
+	for _, snapshot := range snapshots {
+		if err := s.blobStore.Delete(snapshot.Blob_key); err != nil {
+			fmt.Println("delete snapshot failed:", err.Error())
+			continue
+		}
+		if err := s.deviceRepo.DeleteSnapshot(snapshot.ID); err != nil {
+			fmt.Println("delete snapshot failed:", err.Error())
+		}
+	}
+}
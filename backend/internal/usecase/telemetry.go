@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"fmt"
+	"go-jwt/internal/config"
+	entity "go-jwt/internal/entity"
+	"sort"
+	"time"
+)
+
+var (
+	telemetryMaxReadings = config.Int("TELEMETRY_MAX_READINGS", 1000)
+	// the clock of a device can be a little ahead of the clock of the server
+	telemetryMaxClockSkew = config.Duration("TELEMETRY_MAX_CLOCK_SKEW", 5*time.Minute)
+)
+
+// the readings sent without time are this far apart, a datetime column only keeps a few milliseconds
+const telemetryUntimedStep = 10 * time.Millisecond
+
+// RecordTelemetry writes the readings of the device as data records and returns how many were new.
+// The readings without time were taken just now, in the order they came. A reading in the future or twice at
+// the same time is refused.
+// A suspicious reading of a sensor is written but flagged as an anomaly.
+func (s *deviceUsecase) RecordTelemetry(device *entity.Device, readings []entity.Reading) (int64, error) {
+	if len(readings) == 0 {
+		return 0, fmt.Errorf("%w: no reading", entity.ErrInvalidTelemetry)
+	}
+	if len(readings) > telemetryMaxReadings {
+		return 0, fmt.Errorf("%w: %d readings, the limit is %d", entity.ErrInvalidTelemetry, len(readings), telemetryMaxReadings)
+	}
+
+	now := time.Now()
+	// the readings without time are stamped apart in the order they came, the last one now
+	untimed := 0
+	for _, reading := range readings {
+		if reading.Time == nil {
+			untimed++
+		}
+	}
+
+	records := make([]entity.DataRecord, len(readings))
+	// the same instant in two zones is the same time
+	seen := make(map[int64]bool, len(readings))
+	for i, reading := range readings {
+		var t time.Time
+		if reading.Time != nil {
+			t = *reading.Time
+		} else {
+			untimed--
+			t = now.Add(-time.Duration(untimed) * telemetryUntimedStep)
+		}
+		if t.After(now.Add(telemetryMaxClockSkew)) {
+			return 0, fmt.Errorf("%w: reading %d is in the future", entity.ErrInvalidTelemetry, i+1)
+		}
+		// the time is part of the primary key of Data_record
+		if seen[t.UTC().UnixNano()] {
+			return 0, fmt.Errorf("%w: reading %d has the same time as an earlier reading", entity.ErrInvalidTelemetry, i+1)
+		}
+		seen[t.UTC().UnixNano()] = true
+		if err := s.deviceTypes.ValidateData(device.Type, reading.Data); err != nil {
+			return 0, fmt.Errorf("reading %d: %w", i+1, err)
+		}
+
+		records[i] = entity.DataRecord{
+			Device_id:    device.ID,
+			Time:         t,
+			Device_data:  reading.Data,
+			Device_state: reading.State,
+		}
+	}
+
+	// the detector follows the sensor in the order of the readings
+	sort.Slice(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	values := make([]float64, len(records))
+	for i := range records {
+		values[i] = records[i].Device_data
+	}
+	results, stored := s.monitor.CheckReadings(device, values)
+	for i := range records {
+		records[i].Anomaly, records[i].Anomaly_reason = results[i].Anomaly, results[i].Reason
+	}
+
+	recorded, err := s.deviceRepo.CreateDataRecords(device.ID, records)
+	if err != nil {
+		return 0, err
+	}
+	stored()
+	return recorded, nil
+}
@@ -7,44 +7,73 @@ import (
 	external "go-jwt/internal/usecase/external"
 	"strconv"
 	"strings"
+	"sync"
 )
 
-func NewUserUsecase(userRepo repository.UserRepository) UserUsecase {
+func NewUserUsecase(userRepo repository.UserRepository, commandService CommandUsecase, deviceTypes DeviceTypeUsecase) UserUsecase {
 	return &userUsecase{
-		userRepo: userRepo,
+		userRepo:       userRepo,
+		commandService: commandService,
+		deviceTypes:    deviceTypes,
 	}
 }
 
 type UserUsecase interface {
 	// CreateUser(ctx context.Context, user *entity.User) (*entity.User, error)
 	GetUser(id int) (*entity.User, error)
+	IsHouseMember(userID int, houseID int) (bool, error)
+	GetHouseIDs(userID int) ([]int, error)
 	// UpdateUser(ctx context.Context, id string, data *entity.User) (*entity.User, error)
 	// DeleteUser(ctx context.Context, id string) error
 	AuthenticateUser(username string, password string) (*entity.User, string, []int, error)
 	GetTempAndHumid(house_id int) (float64, float64, error)
 	GetDashboardData(house_id int) (float64, float64, float64, float64, error)
+	GetDashboardFeeds() (map[string]string, error)
+	GetOfflineDevices(house_id int) ([]entity.Device, error)
+	GetUserDashboard(userID int) ([]entity.HouseDashboard, error)
+	GetHouseDashboard(userID int, houseID int) (*entity.Dashboard, error)
+	GetDashboardLayout(userID int, houseID int) (*entity.DashboardLayout, error)
+	SaveDashboardLayout(layout *entity.DashboardLayout) error
+	ResetDashboardLayout(userID int, houseID int) error
 	GetHouseSettingByHouseID(house_id int) ([]entity.HouseSetting, error)
 	GetSetOfHouseSetting(house_id int, settingName string) ([]entity.Set, error)
-	GetActivityLogByHouseID(house_id int) ([]entity.ActivityLog, error)
+	GetHouseSetting(house_id int, settingName string) ([]entity.Set, error)
+	GetActivityLogByHouseID(house_id int, roomID *int) ([]entity.ActivityLog, error)
+	GetActivityLog(houseID int, filter entity.ActivityFilter) (*entity.ActivityPage, error)
 	UpdateDeviceData(deviceID int, data float64, house_id int, setting string) error
 	UpdataDeviceState(deviceID int, state bool, house_id int, setting string) error
 	UpdateManySets([]entity.Set) error
+	ActivateHouseSetting(house_id int, settingName string, actor entity.Actor) ([]entity.SetResult, error)
+	CreateHouseSetting(house_id int, settingName string, sets []entity.Set) error
+	SnapshotHouseSetting(house_id int, settingName string) ([]entity.Set, error)
+	CloneHouseSetting(house_id int, settingName string, newName string) ([]entity.Set, error)
+	RenameHouseSetting(house_id int, settingName string, newName string) error
+	ReplaceSets(house_id int, settingName string, sets []entity.Set) error
+	DeleteHouseSetting(house_id int, settingName string) error
 	GetAllNotifications(userID int) ([]entity.Notification, error)
 	GetUnreadNotifications(userID int) ([]entity.Notification, error)
+	GetNotifications(userID int, filter entity.NotificationFilter) ([]entity.Notification, error)
+	CountUnreadNotifications(userID int, houseID *int) (int64, error)
+	MarkNotificationRead(userID int, notificationID int, read bool) error
+	MarkAllNotificationsRead(userID int, houseID *int) (int64, error)
+	ArchiveNotification(userID int, notificationID int) error
+	DeleteNotification(userID int, notificationID int) error
 	CreateNotification(userID int, houseId int, notification *entity.Notification) error
 	CreateActivityLog(*entity.ActivityLog) error
-	TurnOnLight(houseID int) error
-	TurnOffLight(houseID int) error
-	TurnOnFan(houseID int) error
-	TurnOffFan(houseID int) error
-	OpenDoor(houseID int) error
-	CloseDoor(houseID int) error
-	UpdateLightLevel(houseID int, lightLevel float64) error
-	UpdateFanSpeed(houseID int, fanSpeed float64) error
+	TurnOnLight(houseID int, actor entity.Actor) (*entity.DeviceCommand, error)
+	TurnOffLight(houseID int, actor entity.Actor) (*entity.DeviceCommand, error)
+	TurnOnFan(houseID int, actor entity.Actor) (*entity.DeviceCommand, error)
+	TurnOffFan(houseID int, actor entity.Actor) (*entity.DeviceCommand, error)
+	OpenDoor(houseID int, actor entity.Actor) (*entity.DeviceCommand, error)
+	CloseDoor(houseID int, actor entity.Actor) (*entity.DeviceCommand, error)
+	UpdateLightLevel(houseID int, lightLevel float64, actor entity.Actor) (*entity.DeviceCommand, error)
+	UpdateFanSpeed(houseID int, fanSpeed float64, actor entity.Actor) (*entity.DeviceCommand, error)
 }
 
 type userUsecase struct {
-	userRepo repository.UserRepository
+	userRepo       repository.UserRepository
+	commandService CommandUsecase
+	deviceTypes    DeviceTypeUsecase
 }
 
 // func (s *userUsecase) CreateUser(ctx context.Context, user *entity.User) (*entity.User, error) {
@@ -55,6 +84,16 @@ func (s *userUsecase) GetUser(id int) (*entity.User, error) {
 	return s.userRepo.GetUserByID(id)
 }
 
+// IsHouseMember tells whether the user owns the house
+func (s *userUsecase) IsHouseMember(userID int, houseID int) (bool, error) {
+	return s.userRepo.IsHouseMember(userID, houseID)
+}
+
+// GetHouseIDs returns the houses the user owns
+func (s *userUsecase) GetHouseIDs(userID int) ([]int, error) {
+	return s.userRepo.GetHouseID(userID)
+}
+
 func (s *userUsecase) GetTempAndHumid(house_id int) (float64, float64, error) {
 	return s.userRepo.GetTempAndHumid(house_id)
 }
@@ -84,7 +123,7 @@ func (s *userUsecase) AuthenticateUser(username string, password string) (*entit
 		return nil, "", nil, entity.ErrUserPasswordNotMatch
 	}
 
-	token, err := token.GenerateToken(user.Username + strconv.Itoa(user.ID) + user.Password)
+	token, err := token.GenerateToken(user.Username, user.ID)
 
 	if err != nil {
 		return nil, "", nil, err
@@ -110,8 +149,20 @@ func (s *userUsecase) GetSetOfHouseSetting(house_id int, settingName string) ([]
 	return s.userRepo.GetSetOfHouseSetting(house_id, settingName)
 }
 
-func (s *userUsecase) GetActivityLogByHouseID(house_id int) ([]entity.ActivityLog, error) {
-	return s.userRepo.GetActivityLogByHouseID(house_id)
+// GetHouseSetting returns the sets of the setting, ErrHouseSettingNotFound when the house has no such setting
+func (s *userUsecase) GetHouseSetting(house_id int, settingName string) ([]entity.Set, error) {
+	exists, err := s.userRepo.HouseSettingExists(house_id, settingName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, entity.ErrHouseSettingNotFound
+	}
+	return s.userRepo.GetSetOfHouseSetting(house_id, settingName)
+}
+
+func (s *userUsecase) GetActivityLogByHouseID(house_id int, roomID *int) ([]entity.ActivityLog, error) {
+	return s.userRepo.GetActivityLogByHouseID(house_id, roomID)
 }
 
 func (s *userUsecase) UpdateDeviceData(deviceID int, data float64, house_id int, setting string) error {
@@ -126,18 +177,110 @@ func (s *userUsecase) GetDashboardData(house_id int) (float64, float64, float64,
 	return s.userRepo.GetDashboardData(house_id)
 }
 
+// GetOfflineDevices returns the devices that stopped sending data, a device never seen is not offline
+func (s *userUsecase) GetOfflineDevices(house_id int) ([]entity.Device, error) {
+	devices, err := s.userRepo.GetDevicesByHouseID(house_id)
+	if err != nil {
+		return nil, err
+	}
+	offline := []entity.Device{}
+	for _, device := range devices {
+		if !device.Online && device.Last_seen != nil {
+			offline = append(offline, device)
+		}
+	}
+	return offline, nil
+}
+
+// the Adafruit feeds shown on the dashboard
+var dashboardFeeds = map[string]string{
+	"light":       "iot-alarm",
+	"light_level": "iot-state",
+	"fan":         "iot-fan",
+	"fan_speed":   "iot-fanspeed",
+	"door":        "iot-door",
+	"temperature": "iot-temperature",
+	"humidity":    "iot-humidity",
+}
+
+// GetDashboardFeeds reads the last value of every dashboard feed at the same time
+func (s *userUsecase) GetDashboardFeeds() (map[string]string, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	res := make(map[string]string, len(dashboardFeeds))
+
+	for key, feed := range dashboardFeeds {
+		wg.Add(1)
+		go func(key string, feed string) {
+			defer wg.Done()
+			value, err := external.GetLastFeedValue(feed)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			res[key] = value.Value
+		}(key, feed)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return res, nil
+}
+
 func (s *userUsecase) UpdateManySets(sets []entity.Set) error {
 	// loop through the sets and update each set
 	s.userRepo.UpdateManySets(sets)
 	return nil
 }
 
+func (s *userUsecase) ActivateHouseSetting(house_id int, settingName string, actor entity.Actor) ([]entity.SetResult, error) {
+	return activateHouseSetting(s.userRepo, s.commandService, house_id, settingName, actor)
+}
+
+func (s *userUsecase) CreateHouseSetting(house_id int, settingName string, sets []entity.Set) error {
+	return createHouseSetting(s.userRepo, s.commandService, house_id, settingName, sets)
+}
+
+func (s *userUsecase) SnapshotHouseSetting(house_id int, settingName string) ([]entity.Set, error) {
+	return snapshotHouseSetting(s.userRepo, s.commandService, house_id, settingName)
+}
+
+func (s *userUsecase) CloneHouseSetting(house_id int, settingName string, newName string) ([]entity.Set, error) {
+	return cloneHouseSetting(s.userRepo, s.commandService, house_id, settingName, newName)
+}
+
+func (s *userUsecase) RenameHouseSetting(house_id int, settingName string, newName string) error {
+	if err := validateSettingName(newName); err != nil {
+		return err
+	}
+	return s.userRepo.RenameHouseSetting(house_id, settingName, newName)
+}
+
+func (s *userUsecase) ReplaceSets(house_id int, settingName string, sets []entity.Set) error {
+	if err := validateSets(s.userRepo, s.commandService, house_id, settingName, sets); err != nil {
+		return err
+	}
+	return s.userRepo.ReplaceSets(house_id, settingName, sets)
+}
+
+func (s *userUsecase) DeleteHouseSetting(house_id int, settingName string) error {
+	return s.userRepo.DeleteHouseSetting(house_id, settingName)
+}
+
 func (s *userUsecase) GetAllNotifications(userID int) ([]entity.Notification, error) {
-	return s.userRepo.GetAllNotifications(userID)
+	return s.userRepo.GetNotifications(userID, entity.NotificationFilter{})
 }
 
 func (s *userUsecase) GetUnreadNotifications(userID int) ([]entity.Notification, error) {
-	return s.userRepo.GetUnreadNotifications(userID)
+	return s.userRepo.GetNotifications(userID, entity.NotificationFilter{Unread: true})
 }
 
 func (s *userUsecase) CreateNotification(userID int, houseId int, notification *entity.Notification) error {
@@ -148,34 +291,36 @@ func (s *userUsecase) CreateActivityLog(activityLog *entity.ActivityLog) error {
 	return s.userRepo.CreateActivityLog(activityLog)
 }
 
-func (s *userUsecase) TurnOnLight(houseID int) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.LightOn{}, nil)
+// the device commands go through the outbox, the activity is logged when the device acknowledges them
+
+func (s *userUsecase) TurnOnLight(houseID int, actor entity.Actor) (*entity.DeviceCommand, error) {
+	return s.commandService.SendCommand(houseID, 0, "Light", CommandOn, 0, "Turn on the light", actor)
 }
 
-func (s *userUsecase) TurnOffLight(houseID int) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.LightOff{}, nil)
+func (s *userUsecase) TurnOffLight(houseID int, actor entity.Actor) (*entity.DeviceCommand, error) {
+	return s.commandService.SendCommand(houseID, 0, "Light", CommandOff, 0, "Turn off the light", actor)
 }
 
-func (s *userUsecase) TurnOnFan(houseID int) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.FanOn{}, nil)
+func (s *userUsecase) TurnOnFan(houseID int, actor entity.Actor) (*entity.DeviceCommand, error) {
+	return s.commandService.SendCommand(houseID, 0, "Fan", CommandOn, 0, "Turn on the fan", actor)
 }
 
-func (s *userUsecase) TurnOffFan(houseID int) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.FanOff{}, nil)
+func (s *userUsecase) TurnOffFan(houseID int, actor entity.Actor) (*entity.DeviceCommand, error) {
+	return s.commandService.SendCommand(houseID, 0, "Fan", CommandOff, 0, "Turn off the fan", actor)
 }
 
-func (s *userUsecase) OpenDoor(houseID int) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.DoorOpen{}, nil)
+func (s *userUsecase) OpenDoor(houseID int, actor entity.Actor) (*entity.DeviceCommand, error) {
+	return s.commandService.SendCommand(houseID, 0, "Door", CommandOpen, 0, "Open the door", actor)
 }
 
-func (s *userUsecase) CloseDoor(houseID int) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.DoorClose{}, nil)
+func (s *userUsecase) CloseDoor(houseID int, actor entity.Actor) (*entity.DeviceCommand, error) {
+	return s.commandService.SendCommand(houseID, 0, "Door", CommandClose, 0, "Close the door", actor)
 }
 
-func (s *userUsecase) UpdateLightLevel(houseID int, lightLevel float64) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.LightLevel{LightLevel: lightLevel}, nil)
+func (s *userUsecase) UpdateLightLevel(houseID int, lightLevel float64, actor entity.Actor) (*entity.DeviceCommand, error) {
+	return s.commandService.SendCommand(houseID, 0, "Light", CommandLevel, lightLevel, "Update the light level to "+strconv.FormatFloat(lightLevel, 'f', -1, 64), actor)
 }
 
-func (s *userUsecase) UpdateFanSpeed(houseID int, fanSpeed float64) error {
-	return external.NewExternalServiceAdapter(&external.AdaFruitService{}).Execute(&external.FanSpeed{FanSpeed: fanSpeed}, nil)
+func (s *userUsecase) UpdateFanSpeed(houseID int, fanSpeed float64, actor entity.Actor) (*entity.DeviceCommand, error) {
+	return s.commandService.SendCommand(houseID, 0, "Fan", CommandSpeed, fanSpeed, "Update the fan speed to "+strconv.FormatFloat(fanSpeed, 'f', -1, 64), actor)
 }
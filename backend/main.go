@@ -1,8 +1,16 @@
 package main
 
-import "go-jwt/cmd"
+import (
+	"go-jwt/cmd"
+	"os"
+)
 
 func main() {
+	// go run . audit verify 1, see cmd/audit.go
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(cmd.Audit(os.Args[2:]))
+	}
+
 	s := cmd.NewServer()
 	s.Start()
 }